@@ -0,0 +1,461 @@
+// Package clamd provides a client for the ClamAV daemon protocol.
+package clamd
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Operations that can fail.
+const (
+	OpDial  = "dial"
+	OpSend  = "send"
+	OpRead  = "read"
+	OpParse = "parse"
+)
+
+// ErrUnexpectedReply is wrapped by the errors of the replies the client can't parse.
+var ErrUnexpectedReply = errors.New("unexpected reply")
+
+// Error is the error returned by the client when a command fails.
+type Error struct {
+	// Op is the failed operation: OpDial, OpSend, OpRead or OpParse.
+	Op string
+	// Cmd is the command, if any.
+	Cmd string
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Cmd == "" {
+		return "clamd: " + e.Op + ": " + e.Err.Error()
+	}
+	return "clamd: " + e.Op + " " + e.Cmd + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Dialer dials ClamAV daemon sockets. It's implemented by net.Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithTimeout sets the timeout of connecting to the daemon, of the TLS handshake and of every read
+// and write. The context deadline, if earlier, takes precedence.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithTLSConfig makes the client connect to TCP sockets using TLS with the config.
+// Addresses with the tls and tcp+tls schemes use TLS even without it.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = cfg
+	}
+}
+
+// WithDialer makes the client connect to the daemon using the dialer.
+func WithDialer(d Dialer) Option {
+	return func(c *Client) {
+		c.dialer = d
+	}
+}
+
+// Client is a ClamAV daemon client. Every command is sent using a new connection.
+// A Client is safe for concurrent use.
+type Client struct {
+	address   *url.URL
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	dialer    Dialer
+}
+
+// Ping checks the daemon replies to the PING command.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, func(conn *conn) error {
+		return conn.ping(ctx)
+	})
+}
+
+// Version returns the daemon and the database versions.
+func (c *Client) Version(ctx context.Context) (v *Version, err error) {
+	err = c.do(ctx, func(conn *conn) error {
+		v, err = conn.version(ctx)
+		return err
+	})
+	return
+}
+
+// VersionCommands returns the daemon and the database versions
+// along with the commands supported by the daemon.
+func (c *Client) VersionCommands(ctx context.Context) (v *Version, commands []string, err error) {
+	err = c.do(ctx, func(conn *conn) error {
+		b, err := conn.command(ctx, "VERSIONCOMMANDS", nil)
+		if err != nil {
+			return err
+		}
+		s, list, ok := strings.Cut(string(b), "| COMMANDS:")
+		if !ok {
+			return unexpectedReply("VERSIONCOMMANDS", b)
+		}
+		if v, err = ParseVersion([]byte(strings.TrimSpace(s))); err != nil {
+			return &Error{Op: OpParse, Cmd: "VERSIONCOMMANDS", Err: err}
+		}
+		commands = strings.Fields(list)
+		return nil
+	})
+	return
+}
+
+// Stats returns the raw reply to the STATS command describing the daemon
+// thread pools, queues and memory usage.
+func (c *Client) Stats(ctx context.Context) (b []byte, err error) {
+	err = c.do(ctx, func(conn *conn) error {
+		b, err = conn.stats(ctx)
+		return err
+	})
+	return
+}
+
+// Reload makes the daemon reload the databases.
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, func(conn *conn) error {
+		b, err := conn.command(ctx, "RELOAD", nil)
+		if err != nil {
+			return err
+		}
+		if string(b) != "RELOADING" {
+			return unexpectedReply("RELOAD", b)
+		}
+		return nil
+	})
+}
+
+// Scan scans a file or a directory on the daemon host, stopping at the first detection.
+func (c *Client) Scan(ctx context.Context, path string) ([]Result, error) {
+	return c.scan(ctx, "SCAN", path)
+}
+
+// ContScan scans a file or a directory on the daemon host, not stopping at detections.
+func (c *Client) ContScan(ctx context.Context, path string) ([]Result, error) {
+	return c.scan(ctx, "CONTSCAN", path)
+}
+
+// MultiScan scans a file or a directory on the daemon host in parallel.
+func (c *Client) MultiScan(ctx context.Context, path string) ([]Result, error) {
+	return c.scan(ctx, "MULTISCAN", path)
+}
+
+// AllMatchScan scans a file or a directory on the daemon host, reporting all the matching signatures.
+func (c *Client) AllMatchScan(ctx context.Context, path string) ([]Result, error) {
+	return c.scan(ctx, "ALLMATCHSCAN", path)
+}
+
+func (c *Client) scan(ctx context.Context, cmd, path string) (results []Result, err error) {
+	err = c.do(ctx, func(conn *conn) error {
+		if err := conn.send(ctx, cmd+" "+path, nil); err != nil {
+			return err
+		}
+		// The daemon closes the connection after the last result.
+		for {
+			b, err := conn.read(ctx, cmd)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			r, err := ParseResult(b)
+			if err != nil {
+				return &Error{Op: OpParse, Cmd: cmd, Err: err}
+			}
+			results = append(results, *r)
+		}
+	})
+	return
+}
+
+// Instream streams data to the daemon to scan it.
+func (c *Client) Instream(ctx context.Context, r io.Reader) (res *Result, err error) {
+	err = c.do(ctx, func(conn *conn) error {
+		res, err = conn.instream(ctx, r)
+		return err
+	})
+	return
+}
+
+// Session starts an IDSESSION session, so several commands share a connection.
+// The session must be closed.
+func (c *Client) Session(ctx context.Context) (*Session, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.send(ctx, "IDSESSION", nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.session = true
+	return &Session{conn: conn}, nil
+}
+
+func (c *Client) do(ctx context.Context, f func(conn *conn) error) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return f(conn)
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	var (
+		network, addr = c.address.Scheme, c.address.Host
+		tlsConfig     = c.tlsConfig
+	)
+	switch network {
+	case "unix":
+		addr = c.address.Path
+		tlsConfig = nil
+	case "tls", "tcp+tls":
+		network = "tcp"
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+	}
+	dialer := c.dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	// The timeout applies to connecting and to the TLS handshake separately.
+	withTimeout := func() (context.Context, context.CancelFunc) {
+		if c.timeout > 0 {
+			return context.WithTimeout(ctx, c.timeout)
+		}
+		return context.WithCancel(ctx)
+	}
+	dialCtx, cancel := withTimeout()
+	defer cancel()
+	nc, err := dialer.DialContext(dialCtx, network, addr)
+	if err != nil {
+		return nil, &Error{Op: OpDial, Err: ctxErr(ctx, err)}
+	}
+	if tlsConfig != nil {
+		cfg := tlsConfig
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(nc, cfg)
+		handshakeCtx, cancel := withTimeout()
+		defer cancel()
+		if err = tc.HandshakeContext(handshakeCtx); err != nil {
+			nc.Close()
+			return nil, &Error{Op: OpDial, Err: ctxErr(ctx, err)}
+		}
+		nc = tc
+	}
+	return &conn{
+		Conn:    nc,
+		r:       bufio.NewReader(nc),
+		timeout: c.timeout,
+	}, nil
+}
+
+// NewClient returns a client of the daemon listening on the address.
+// The supported schemes are tcp, tcp4, tcp6, tls, tcp+tls and unix.
+func NewClient(address *url.URL, opts ...Option) *Client {
+	c := &Client{address: address}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// conn is a connection to the daemon.
+type conn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	session bool
+	id      int
+}
+
+// deadline returns the deadline of an I/O operation.
+func (c *conn) deadline(ctx context.Context) time.Time {
+	var t time.Time
+	if c.timeout > 0 {
+		t = time.Now().Add(c.timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+	return t
+}
+
+// watch interrupts the I/O operations in progress when ctx is done.
+func (c *conn) watch(ctx context.Context) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		c.SetDeadline(time.Unix(1, 0))
+	})
+}
+
+// send sends the command followed by data, if any.
+func (c *conn) send(ctx context.Context, cmd string, data []byte) error {
+	name, _, _ := strings.Cut(cmd, " ")
+	if err := c.write(ctx, name, append([]byte("z"+cmd+"\000"), data...)); err != nil {
+		return err
+	}
+	if c.session {
+		c.id++
+	}
+	return nil
+}
+
+// write writes b as a part of the command.
+func (c *conn) write(ctx context.Context, cmd string, b []byte) error {
+	if err := ctx.Err(); err != nil {
+		return &Error{Op: OpSend, Cmd: cmd, Err: err}
+	}
+	defer c.watch(ctx)()
+	c.SetWriteDeadline(c.deadline(ctx))
+	if _, err := c.Write(b); err != nil {
+		return &Error{Op: OpSend, Cmd: cmd, Err: ctxErr(ctx, err)}
+	}
+	return nil
+}
+
+// read reads a reply to the command, stripping the session ID.
+func (c *conn) read(ctx context.Context, cmd string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &Error{Op: OpRead, Cmd: cmd, Err: err}
+	}
+	defer c.watch(ctx)()
+	c.SetReadDeadline(c.deadline(ctx))
+	b, err := c.r.ReadBytes('\000')
+	if err == io.EOF && len(b) == 0 {
+		return nil, &Error{Op: OpRead, Cmd: cmd, Err: io.EOF}
+	}
+	if err != nil {
+		return nil, &Error{Op: OpRead, Cmd: cmd, Err: ctxErr(ctx, err)}
+	}
+	b = b[:len(b)-1]
+	if c.session {
+		prefix := fmt.Sprintf("%d: ", c.id)
+		if !strings.HasPrefix(string(b), prefix) {
+			return nil, &Error{Op: OpParse, Cmd: cmd, Err: fmt.Errorf("missing session ID %d in reply %q", c.id, b)}
+		}
+		b = b[len(prefix):]
+	}
+	return b, nil
+}
+
+func (c *conn) command(ctx context.Context, cmd string, data []byte) ([]byte, error) {
+	if err := c.send(ctx, cmd, data); err != nil {
+		return nil, err
+	}
+	return c.read(ctx, cmd)
+}
+
+func (c *conn) ping(ctx context.Context) error {
+	b, err := c.command(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+	if string(b) != "PONG" {
+		return unexpectedReply("PING", b)
+	}
+	return nil
+}
+
+func (c *conn) version(ctx context.Context) (*Version, error) {
+	b, err := c.command(ctx, "VERSION", nil)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ParseVersion(b)
+	if err != nil {
+		return nil, &Error{Op: OpParse, Cmd: "VERSION", Err: err}
+	}
+	return v, nil
+}
+
+func (c *conn) stats(ctx context.Context) ([]byte, error) {
+	return c.command(ctx, "STATS", nil)
+}
+
+// maxChunkSize is the maximum size of an INSTREAM chunk.
+const maxChunkSize = 64 * 1024
+
+// instream streams r to the daemon writing every chunk as soon as it's read.
+// The daemon closes the connection once the stream exceeds its StreamMaxLength,
+// so its reply is still read if writing fails.
+func (c *conn) instream(ctx context.Context, r io.Reader) (*Result, error) {
+	if err := c.send(ctx, "INSTREAM", nil); err != nil {
+		return nil, err
+	}
+	// The chunks are prefixed with their size.
+	buf := make([]byte, 4+maxChunkSize)
+	var werr error
+	for werr == nil {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			werr = c.write(ctx, "INSTREAM", buf[:4+n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if werr == nil {
+				werr = c.write(ctx, "INSTREAM", binary.BigEndian.AppendUint32(nil, 0))
+			}
+			break
+		}
+		if err != nil {
+			return nil, &Error{Op: OpSend, Cmd: "INSTREAM", Err: err}
+		}
+	}
+	b, err := c.read(ctx, "INSTREAM")
+	if err != nil {
+		if werr != nil {
+			return nil, werr
+		}
+		return nil, err
+	}
+	res, err := ParseResult(b)
+	if err != nil {
+		return nil, &Error{Op: OpParse, Cmd: "INSTREAM", Err: err}
+	}
+	return res, nil
+}
+
+func unexpectedReply(cmd string, b []byte) error {
+	return &Error{Op: OpParse, Cmd: cmd, Err: fmt.Errorf("%w %q", ErrUnexpectedReply, b)}
+}
+
+// ctxErr returns the context error instead of err if the context is done.
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// The connection deadline set from the context one may pass
+	// before the context is done.
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
@@ -0,0 +1,409 @@
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	var streamed bytes.Buffer
+	client := NewClient(serve(t, map[string][]string{
+		"PING":                 {"PONG"},
+		"VERSION":              {"ClamAV 1.4.1/27482/Mon Dec  9 09:37:53 2024"},
+		"VERSIONCOMMANDS":      {"ClamAV 1.4.1/27482/Mon Dec  9 09:37:53 2024| COMMANDS: SCAN PING VERSION"},
+		"STATS":                {"POOLS: 1\n\nSTATE: VALID PRIMARY\nEND"},
+		"RELOAD":               {"RELOADING"},
+		"CONTSCAN /tmp":        {"/tmp/a: Eicar FOUND", "/tmp/b: Eicar FOUND"},
+		"ALLMATCHSCAN /tmp/a":  {"/tmp/a: Eicar FOUND", "/tmp/a: Eicar-2 FOUND"},
+		"SCAN /tmp/missing":    {"/tmp/missing: lstat() failed: No such file or directory. ERROR"},
+		"MULTISCAN /tmp/clean": {"/tmp/clean: OK"},
+		"INSTREAM":             {"stream: OK"},
+	}, &streamed), WithTimeout(time.Second))
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("Ping() = %v; want nil", err)
+	}
+	v, err := client.Version(ctx)
+	if err != nil {
+		t.Errorf("Version() = _, %v; want nil", err)
+	} else if v.Engine != "1.4.1" || v.Database != 27482 {
+		t.Errorf("Version() = %+v, nil; want 1.4.1/27482", v)
+	}
+	v, commands, err := client.VersionCommands(ctx)
+	if err != nil {
+		t.Errorf("VersionCommands() = _, _, %v; want nil", err)
+	} else if v.Engine != "1.4.1" || !reflect.DeepEqual(commands, []string{"SCAN", "PING", "VERSION"}) {
+		t.Errorf("VersionCommands() = %+v, %q, nil; want 1.4.1, [SCAN PING VERSION]", v, commands)
+	}
+	b, err := client.Stats(ctx)
+	if err != nil {
+		t.Errorf("Stats() = _, %v; want nil", err)
+	} else if !strings.HasPrefix(string(b), "POOLS: 1\n") {
+		t.Errorf("Stats() = %q, nil; want POOLS: 1...", b)
+	}
+	if err = client.Reload(ctx); err != nil {
+		t.Errorf("Reload() = %v; want nil", err)
+	}
+	scans := []struct {
+		name string
+		scan func(ctx context.Context, path string) ([]Result, error)
+		path string
+		want []Result
+	}{
+		{
+			name: "Scan",
+			scan: client.Scan,
+			path: "/tmp/missing",
+			want: []Result{{Path: "/tmp/missing", Status: StatusError, Err: "lstat() failed: No such file or directory."}},
+		},
+		{
+			name: "ContScan",
+			scan: client.ContScan,
+			path: "/tmp",
+			want: []Result{
+				{Path: "/tmp/a", Status: StatusFound, Signature: "Eicar"},
+				{Path: "/tmp/b", Status: StatusFound, Signature: "Eicar"},
+			},
+		},
+		{
+			name: "MultiScan",
+			scan: client.MultiScan,
+			path: "/tmp/clean",
+			want: []Result{{Path: "/tmp/clean", Status: StatusOK}},
+		},
+		{
+			name: "AllMatchScan",
+			scan: client.AllMatchScan,
+			path: "/tmp/a",
+			want: []Result{
+				{Path: "/tmp/a", Status: StatusFound, Signature: "Eicar"},
+				{Path: "/tmp/a", Status: StatusFound, Signature: "Eicar-2"},
+			},
+		},
+	}
+	for _, test := range scans {
+		results, err := test.scan(ctx, test.path)
+		if err != nil {
+			t.Errorf("%s() = _, %v; want nil", test.name, err)
+		} else if !reflect.DeepEqual(results, test.want) {
+			t.Errorf("%s() = %+v, nil; want %+v", test.name, results, test.want)
+		}
+	}
+	data := bytes.Repeat([]byte("0123456789abcdef"), maxChunkSize/8)
+	r, err := client.Instream(ctx, bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Instream() = _, %v; want nil", err)
+	} else if r.Status != StatusOK {
+		t.Errorf("Instream() = %+v, nil; want OK", r)
+	}
+	if !bytes.Equal(streamed.Bytes(), data) {
+		t.Errorf("streamed %d bytes; want %d", streamed.Len(), len(data))
+	}
+}
+
+func TestSession(t *testing.T) {
+	client := NewClient(serve(t, map[string][]string{
+		"PING":     {"PONG"},
+		"VERSION":  {"ClamAV 1.4.1"},
+		"STATS":    {"POOLS: 1\nEND"},
+		"INSTREAM": {"stream: Eicar FOUND"},
+	}, nil), WithTimeout(time.Second))
+	ctx := context.Background()
+	s, err := client.Session(ctx)
+	if err != nil {
+		t.Fatalf("Session() = _, %v; want nil", err)
+	}
+	defer s.Close()
+	if err = s.Ping(ctx); err != nil {
+		t.Errorf("Ping() = %v; want nil", err)
+	}
+	if v, err := s.Version(ctx); err != nil || v.Engine != "1.4.1" {
+		t.Errorf("Version() = %+v, %v; want 1.4.1, nil", v, err)
+	}
+	if b, err := s.Stats(ctx); err != nil || string(b) != "POOLS: 1\nEND" {
+		t.Errorf("Stats() = %q, %v; want POOLS: 1\\nEND, nil", b, err)
+	}
+	if r, err := s.Instream(ctx, strings.NewReader("foo")); err != nil || r.Signature != "Eicar" {
+		t.Errorf("Instream() = %+v, %v; want Eicar, nil", r, err)
+	}
+}
+
+func TestClient_Instream_SizeLimit(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	// The fake daemon replies and closes the connection after 1 MiB like clamd with StreamMaxLength 1M.
+	const limit = 1024 * 1024
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if _, err = r.ReadString('\000'); err != nil {
+			return
+		}
+		for total := 0; total <= limit; {
+			var n uint32
+			if err = binary.Read(r, binary.BigEndian, &n); err != nil || n == 0 {
+				return
+			}
+			if _, err = io.CopyN(io.Discard, r, int64(n)); err != nil {
+				return
+			}
+			total += int(n)
+		}
+		conn.Write([]byte("INSTREAM size limit exceeded. ERROR\000"))
+	}()
+	client := NewClient(&url.URL{Scheme: "tcp", Host: l.Addr().String()}, WithTimeout(5*time.Second))
+	// The stream is much larger than the limit, so it's never read as a whole.
+	res, err := client.Instream(context.Background(), io.LimitReader(zeroReader{}, 1<<30))
+	if err != nil {
+		t.Fatalf("Instream() = _, %v; want nil", err)
+	}
+	if res.Status != StatusError || res.Err != "INSTREAM size limit exceeded." {
+		t.Errorf("Instream() = %+v, nil; want INSTREAM size limit exceeded. ERROR", res)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+func TestClient_TLS(t *testing.T) {
+	// Borrow the test certificate of an HTTPS server.
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	cfg, pool := srv.TLS, x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	srv.Close()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveListener(t, l, map[string][]string{
+		"PING": {"PONG"},
+	}, nil)
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	tests := []struct {
+		address string
+		cfg     *tls.Config
+		wantErr bool
+	}{
+		{address: "tls://127.0.0.1:" + port, cfg: &tls.Config{RootCAs: pool}},
+		{address: "tcp+tls://127.0.0.1:" + port, cfg: &tls.Config{RootCAs: pool}},
+		{address: "tcp://127.0.0.1:" + port, cfg: &tls.Config{RootCAs: pool, ServerName: "example.com"}},
+		{address: "tls://127.0.0.1:" + port, wantErr: true},
+		{address: "tls://127.0.0.1:" + port, cfg: &tls.Config{InsecureSkipVerify: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			address, _ := url.Parse(test.address)
+			err := NewClient(address, WithTimeout(time.Second), WithTLSConfig(test.cfg)).Ping(context.Background())
+			if test.wantErr {
+				var cerr *Error
+				if !errors.As(err, &cerr) || cerr.Op != OpDial {
+					t.Errorf("Ping() = %v; want Error with Op %q", err, OpDial)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Ping() = %v; want nil", err)
+			}
+		})
+	}
+}
+
+func TestClient_TLSHandshakeTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	// The fake daemon accepts connections and never completes the handshake.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	client := NewClient(&url.URL{Scheme: "tls", Host: l.Addr().String()}, WithTimeout(200*time.Millisecond))
+	start := time.Now()
+	err = client.Ping(context.Background())
+	var cerr *Error
+	if !errors.As(err, &cerr) || cerr.Op != OpDial {
+		t.Errorf("Ping() = %v; want Error with Op %q", err, OpDial)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Ping() took %s; want it timed out", d)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := &url.URL{Scheme: "tcp", Host: l.Addr().String()}
+	l.Close()
+	address := serve(t, map[string][]string{
+		"PING": {"PANG"},
+	}, nil)
+	tests := []struct {
+		name    string
+		address *url.URL
+		op      string
+		err     error
+	}{
+		{name: "dial", address: closed, op: OpDial},
+		{name: "unexpected", address: address, op: OpParse, err: ErrUnexpectedReply},
+		// The fake daemon closes the connection on unknown commands.
+		{name: "read", address: serve(t, nil, nil), op: OpRead, err: io.EOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewClient(test.address, WithTimeout(time.Second)).Ping(context.Background())
+			var cerr *Error
+			if !errors.As(err, &cerr) || cerr.Op != test.op {
+				t.Fatalf("Ping() = %v; want Error with Op %q", err, test.op)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("Ping() = %v; want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestClient_Context(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	// The fake daemon never replies.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	client := NewClient(&url.URL{Scheme: "tcp", Host: l.Addr().String()}, WithTimeout(time.Minute))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if err := client.Ping(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Ping() = %v; want context.Canceled", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Ping() took %s; want it canceled", d)
+	}
+}
+
+// serve starts a fake ClamAV daemon replying to the commands with the given replies.
+// The data streamed using INSTREAM is written to streamed.
+func serve(t *testing.T, replies map[string][]string, streamed io.Writer) *url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveListener(t, l, replies, streamed)
+	return &url.URL{Scheme: "tcp", Host: l.Addr().String()}
+}
+
+func serveListener(t *testing.T, l net.Listener, replies map[string][]string, streamed io.Writer) {
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn, replies, streamed)
+		}
+	}()
+}
+
+func handle(conn net.Conn, replies map[string][]string, streamed io.Writer) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var (
+		session bool
+		id      int
+	)
+	for {
+		cmd, err := r.ReadString('\000')
+		if err != nil {
+			return
+		}
+		cmd = strings.TrimSuffix(strings.TrimPrefix(cmd, "z"), "\000")
+		switch cmd {
+		case "IDSESSION":
+			session = true
+			continue
+		case "END":
+			return
+		case "INSTREAM":
+			for {
+				var n uint32
+				if err = binary.Read(r, binary.BigEndian, &n); err != nil {
+					return
+				}
+				if n == 0 {
+					break
+				}
+				if n > maxChunkSize {
+					return
+				}
+				chunk := make([]byte, n)
+				if _, err = io.ReadFull(r, chunk); err != nil {
+					return
+				}
+				if streamed != nil {
+					streamed.Write(chunk)
+				}
+			}
+		}
+		lines, ok := replies[cmd]
+		if !ok {
+			return
+		}
+		id++
+		for _, reply := range lines {
+			if session {
+				reply = fmt.Sprintf("%d: %s", id, reply)
+			}
+			if _, err = conn.Write([]byte(reply + "\000")); err != nil {
+				return
+			}
+		}
+		if !session {
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package clamd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrNoSocket is returned by Config.Address if the daemon doesn't listen on any socket.
+var ErrNoSocket = errors.New("neither LocalSocket nor TCPSocket is set")
+
+// ErrExampleConfig is returned for configuration files that still have
+// the Example option, which the daemon refuses to start with.
+var ErrExampleConfig = errors.New("example configuration file")
+
+// Config is a daemon configuration file, clamd.conf.
+type Config struct {
+	// Options are the option values by name in the order they appear.
+	// Options that are set several times, e.g. TCPAddr, have several values.
+	Options map[string][]string
+}
+
+// Value returns the last value of the option and whether it's set.
+func (c *Config) Value(name string) (string, bool) {
+	values := c.Options[name]
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// Int returns the integer value of the option or def if it's not set.
+func (c *Config) Int(name string, def int64) (int64, error) {
+	s, ok := c.Value(name)
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q", name, s)
+	}
+	return n, nil
+}
+
+// Size returns the size value of the option in bytes or def if it's not set.
+// The value may have the K or M suffix.
+func (c *Config) Size(name string, def int64) (int64, error) {
+	s, ok := c.Value(name)
+	if !ok {
+		return def, nil
+	}
+	digits, mul := s, int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		digits, mul = s[:len(s)-1], 1024
+	case strings.HasSuffix(s, "m"), strings.HasSuffix(s, "M"):
+		digits, mul = s[:len(s)-1], 1024*1024
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s value %q", name, s)
+	}
+	return n * mul, nil
+}
+
+// Bool returns the boolean value of the option or def if it's not set.
+func (c *Config) Bool(name string, def bool) (bool, error) {
+	s, ok := c.Value(name)
+	if !ok {
+		return def, nil
+	}
+	switch strings.ToLower(s) {
+	case "yes", "true", "1":
+		return true, nil
+	case "no", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid %s value %q", name, s)
+}
+
+// Address returns the address of the daemon socket to connect to, preferring
+// the Unix socket. The TCP socket of a daemon listening on all the interfaces
+// is connected to using the loopback interface.
+func (c *Config) Address() (*url.URL, error) {
+	if path, ok := c.Value("LocalSocket"); ok {
+		return &url.URL{Scheme: "unix", Path: path}, nil
+	}
+	port, ok := c.Value("TCPSocket")
+	if !ok {
+		return nil, ErrNoSocket
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid TCPSocket value %q", port)
+	}
+	host := "127.0.0.1"
+	if addrs := c.Options["TCPAddr"]; len(addrs) > 0 && addrs[0] != "0.0.0.0" && addrs[0] != "::" {
+		host = addrs[0]
+	}
+	return &url.URL{Scheme: "tcp", Host: net.JoinHostPort(host, port)}, nil
+}
+
+// ParseConfig parses the daemon configuration file. Lines have the option
+// name followed by its value, possibly quoted. Comments start with #.
+// It returns ErrExampleConfig if the Example option is set.
+func ParseConfig(r io.Reader) (*Config, error) {
+	c := &Config{Options: make(map[string][]string)}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, value := text, ""
+		if i := strings.IndexAny(text, " \t"); i != -1 {
+			name, value = text[:i], text[i+1:]
+		}
+		if name == "Example" {
+			return nil, ErrExampleConfig
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		if value == "" {
+			return nil, fmt.Errorf("line %d: missing value of option %q", line, name)
+		}
+		c.Options[name] = append(c.Options[name], value)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReadConfig reads the daemon configuration file.
+func ReadConfig(name string) (*Config, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseConfig(f)
+}
@@ -0,0 +1,103 @@
+package clamd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	c, err := ReadConfig("testdata/clamd.conf")
+	if err != nil {
+		t.Fatalf("ReadConfig() = _, %v; want nil", err)
+	}
+	if v, ok := c.Value("DatabaseDirectory"); v != "/var/lib/clamav" || !ok {
+		t.Errorf("Value(DatabaseDirectory) = %q, %t; want /var/lib/clamav, true", v, ok)
+	}
+	if v, ok := c.Value("User"); ok {
+		t.Errorf("Value(User) = %q, true; want false", v)
+	}
+	if want := []string{"127.0.0.1", "::1"}; !reflect.DeepEqual(c.Options["TCPAddr"], want) {
+		t.Errorf("Options[TCPAddr] = %q; want %q", c.Options["TCPAddr"], want)
+	}
+	ints := []struct {
+		name string
+		def  int64
+		want int64
+	}{
+		{name: "MaxThreads", def: 10, want: 20},
+		{name: "MaxQueue", def: 100, want: 100},
+	}
+	for _, test := range ints {
+		if n, err := c.Int(test.name, test.def); n != test.want || err != nil {
+			t.Errorf("Int(%s) = %d, %v; want %d, nil", test.name, n, err, test.want)
+		}
+	}
+	sizes := []struct {
+		name string
+		want int64
+	}{
+		{name: "StreamMaxLength", want: 25 * 1024 * 1024},
+		{name: "MaxFileSize", want: 25600 * 1024},
+		{name: "MaxScanSize", want: 1024 * 1024 * 1024},
+	}
+	for _, test := range sizes {
+		if n, err := c.Size(test.name, 0); n != test.want || err != nil {
+			t.Errorf("Size(%s) = %d, %v; want %d, nil", test.name, n, err, test.want)
+		}
+	}
+	if b, err := c.Bool("LogTime", false); !b || err != nil {
+		t.Errorf("Bool(LogTime) = %t, %v; want true, nil", b, err)
+	}
+	if _, err := c.Int("LogFile", 0); err == nil {
+		t.Error("Int(LogFile) = _, nil; want error")
+	}
+}
+
+func TestReadConfig_Example(t *testing.T) {
+	if _, err := ReadConfig("testdata/clamd-example.conf"); !errors.Is(err, ErrExampleConfig) {
+		t.Errorf("ReadConfig() = _, %v; want ErrExampleConfig", err)
+	}
+}
+
+func TestParseConfig_Invalid(t *testing.T) {
+	if _, err := ParseConfig(strings.NewReader("LogFile\n")); err == nil {
+		t.Error("ParseConfig() = _, nil; want error")
+	}
+}
+
+func TestConfig_Address(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{config: "LocalSocket /run/clamav/clamd.ctl\nTCPSocket 3310\n", want: "unix:///run/clamav/clamd.ctl"},
+		{config: "TCPSocket 3310\n", want: "tcp://127.0.0.1:3310"},
+		{config: "TCPSocket 3310\nTCPAddr 0.0.0.0\n", want: "tcp://127.0.0.1:3310"},
+		{config: "TCPSocket 3310\nTCPAddr ::1\nTCPAddr 127.0.0.1\n", want: "tcp://[::1]:3310"},
+		{config: "TCPSocket foo\n"},
+		{config: "LogTime yes\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.config, func(t *testing.T) {
+			c, err := ParseConfig(strings.NewReader(test.config))
+			if err != nil {
+				t.Fatalf("ParseConfig() = _, %v; want nil", err)
+			}
+			address, err := c.Address()
+			if test.want == "" {
+				if err == nil {
+					t.Errorf("Address() = %s, nil; want error", address)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Address() = _, %v; want nil", err)
+			}
+			if address.String() != test.want {
+				t.Errorf("Address() = %s, nil; want %s", address, test.want)
+			}
+		})
+	}
+}
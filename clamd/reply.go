@@ -0,0 +1,87 @@
+package clamd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TimeLayout is the layout of the database time in the VERSION reply.
+// The time is in the daemon local time zone.
+const TimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// Version is the reply to the VERSION command.
+type Version struct {
+	// Engine is the ClamAV version, e.g. 1.4.1.
+	Engine string
+	// Database is the ClamAV Virus Database version. 0 if no database is loaded.
+	Database uint32
+	// DatabaseTime is the database build time formatted using TimeLayout.
+	DatabaseTime string
+}
+
+// ParseVersion parses the reply to the VERSION command,
+// e.g. "ClamAV 1.4.1/27482/Mon Dec  9 09:37:53 2024".
+func ParseVersion(b []byte) (*Version, error) {
+	s, ok := strings.CutPrefix(string(b), "ClamAV ")
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnexpectedReply, b)
+	}
+	parts := strings.SplitN(s, "/", 3)
+	v := &Version{Engine: parts[0]}
+	if len(parts) == 3 {
+		n, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", ErrUnexpectedReply, b)
+		}
+		v.Database = uint32(n)
+		v.DatabaseTime = parts[2]
+	}
+	return v, nil
+}
+
+// Statuses of scan results.
+const (
+	StatusOK    = "OK"
+	StatusFound = "FOUND"
+	StatusError = "ERROR"
+)
+
+// Result is a scan result of a file or a stream.
+type Result struct {
+	// Path is the scanned file path or "stream" for INSTREAM.
+	// Empty if the error isn't related to a file.
+	Path string
+	// Status is StatusOK, StatusFound or StatusError.
+	Status string
+	// Signature is the name of the detected signature if the status is StatusFound.
+	Signature string
+	// Err is the error message if the status is StatusError.
+	Err string
+}
+
+// ParseResult parses a reply to a scan command,
+// e.g. "stream: Win.Test.EICAR_HDB-1 FOUND".
+func ParseResult(b []byte) (*Result, error) {
+	s := string(b)
+	if msg, ok := strings.CutSuffix(s, " ERROR"); ok {
+		r := &Result{Status: StatusError, Err: msg}
+		if path, msg, ok := strings.Cut(msg, ": "); ok {
+			r.Path, r.Err = path, msg
+		}
+		return r, nil
+	}
+	i := strings.LastIndex(s, ": ")
+	if i == -1 {
+		return nil, fmt.Errorf("%w %q", ErrUnexpectedReply, b)
+	}
+	r := &Result{Path: s[:i]}
+	if sig, ok := strings.CutSuffix(s[i+2:], " "+StatusFound); ok {
+		r.Status, r.Signature = StatusFound, sig
+	} else if s[i+2:] == StatusOK {
+		r.Status = StatusOK
+	} else {
+		return nil, fmt.Errorf("%w %q", ErrUnexpectedReply, b)
+	}
+	return r, nil
+}
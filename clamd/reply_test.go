@@ -0,0 +1,93 @@
+package clamd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  *Version
+	}{
+		{
+			reply: "ClamAV 1.4.1/27482/Mon Dec  9 09:37:53 2024",
+			want: &Version{
+				Engine:       "1.4.1",
+				Database:     27482,
+				DatabaseTime: "Mon Dec  9 09:37:53 2024",
+			},
+		},
+		{
+			reply: "ClamAV 0.103.3",
+			want:  &Version{Engine: "0.103.3"},
+		},
+		{reply: "VERSION"},
+		{reply: "ClamAV 1.4.1/foo/Mon Dec  9 09:37:53 2024"},
+	}
+	for _, test := range tests {
+		t.Run(test.reply, func(t *testing.T) {
+			v, err := ParseVersion([]byte(test.reply))
+			if test.want == nil {
+				if !errors.Is(err, ErrUnexpectedReply) {
+					t.Errorf("ParseVersion() = _, %v; want ErrUnexpectedReply", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVersion() = _, %v; want nil", err)
+			}
+			if !reflect.DeepEqual(v, test.want) {
+				t.Errorf("ParseVersion() = %+v, nil; want %+v", v, test.want)
+			}
+		})
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  *Result
+	}{
+		{
+			reply: "stream: OK",
+			want:  &Result{Path: "stream", Status: StatusOK},
+		},
+		{
+			reply: "stream: Win.Test.EICAR_HDB-1 FOUND",
+			want:  &Result{Path: "stream", Status: StatusFound, Signature: "Win.Test.EICAR_HDB-1"},
+		},
+		{
+			reply: "/tmp/a: b: Eicar-Signature FOUND",
+			want:  &Result{Path: "/tmp/a: b", Status: StatusFound, Signature: "Eicar-Signature"},
+		},
+		{
+			reply: "/tmp/foo: lstat() failed: No such file or directory. ERROR",
+			want:  &Result{Path: "/tmp/foo", Status: StatusError, Err: "lstat() failed: No such file or directory."},
+		},
+		{
+			reply: "INSTREAM size limit exceeded. ERROR",
+			want:  &Result{Status: StatusError, Err: "INSTREAM size limit exceeded."},
+		},
+		{reply: "PONG"},
+		{reply: "stream: foo"},
+	}
+	for _, test := range tests {
+		t.Run(test.reply, func(t *testing.T) {
+			r, err := ParseResult([]byte(test.reply))
+			if test.want == nil {
+				if !errors.Is(err, ErrUnexpectedReply) {
+					t.Errorf("ParseResult() = _, %v; want ErrUnexpectedReply", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseResult() = _, %v; want nil", err)
+			}
+			if !reflect.DeepEqual(r, test.want) {
+				t.Errorf("ParseResult() = %+v, nil; want %+v", r, test.want)
+			}
+		})
+	}
+}
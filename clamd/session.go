@@ -0,0 +1,47 @@
+package clamd
+
+import (
+	"context"
+	"io"
+)
+
+// Session is an IDSESSION session of a Client. Commands of a session
+// share a connection and must not be sent concurrently.
+type Session struct {
+	conn *conn
+}
+
+// Ping checks the daemon replies to the PING command.
+func (s *Session) Ping(ctx context.Context) error {
+	return s.conn.ping(ctx)
+}
+
+// Version returns the daemon and the database versions.
+func (s *Session) Version(ctx context.Context) (*Version, error) {
+	return s.conn.version(ctx)
+}
+
+// Stats returns the raw reply to the STATS command describing the daemon
+// thread pools, queues and memory usage.
+func (s *Session) Stats(ctx context.Context) ([]byte, error) {
+	return s.conn.stats(ctx)
+}
+
+// Instream streams data to the daemon to scan it.
+func (s *Session) Instream(ctx context.Context, r io.Reader) (*Result, error) {
+	return s.conn.instream(ctx, r)
+}
+
+// Close ends the session and closes the connection.
+func (s *Session) Close() error {
+	// The daemon closes the connection after END, so the reply isn't awaited.
+	s.conn.SetWriteDeadline(s.conn.deadline(context.Background()))
+	_, err := s.conn.Write([]byte("zEND\000"))
+	if cerr := s.conn.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return &Error{Op: OpSend, Cmd: "END", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package clamd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Thread pool states.
+const (
+	StateInvalid = "INVALID"
+	StateValid   = "VALID"
+	StateExit    = "EXIT"
+)
+
+// Stats is the reply to the STATS command.
+type Stats struct {
+	Pools  []Pool `json:"pools"`
+	Memory Memory `json:"memory"`
+	// Warnings lists the lines of the reply the parser didn't recognize
+	// along with the inconsistencies it found.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Pool is a thread pool.
+type Pool struct {
+	// State is StateInvalid, StateValid, StateExit or empty if unknown.
+	State   string  `json:"state,omitempty"`
+	Primary bool    `json:"primary"`
+	Threads Threads `json:"threads"`
+	// Queue is nil if the pool has no QUEUE line.
+	Queue    *Queue    `json:"queue,omitempty"`
+	Commands []Command `json:"commands,omitempty"`
+}
+
+// Threads are the thread counts of a pool. Missing counts are nil.
+type Threads struct {
+	Live        *int64 `json:"live,omitempty"`
+	Idle        *int64 `json:"idle,omitempty"`
+	Max         *int64 `json:"max,omitempty"`
+	IdleTimeout *int64 `json:"idle_timeout,omitempty"`
+}
+
+// Queue is the queue of a pool. The wait times are in seconds
+// and are 0 if the queue is empty.
+type Queue struct {
+	Length            int64   `json:"length"`
+	MinWait           float64 `json:"min_wait"`
+	MaxWait           float64 `json:"max_wait"`
+	AvgWait           float64 `json:"avg_wait"`
+	InvalidTimestamps int64   `json:"invalid_timestamps,omitempty"`
+}
+
+// Command is a command being processed in a pool.
+type Command struct {
+	Name string `json:"name"`
+	// Elapsed is the time the command has been running in seconds.
+	Elapsed float64 `json:"elapsed"`
+	// File is the scanned file, if any.
+	File string `json:"file,omitempty"`
+}
+
+// Memory is the memory usage of the daemon in bytes. Missing or unavailable stats are nil.
+type Memory struct {
+	Heap       *uint64 `json:"heap,omitempty"`
+	Mmap       *uint64 `json:"mmap,omitempty"`
+	Used       *uint64 `json:"used,omitempty"`
+	Free       *uint64 `json:"free,omitempty"`
+	Releasable *uint64 `json:"releasable,omitempty"`
+	PoolsUsed  *uint64 `json:"pools_used,omitempty"`
+	PoolsTotal *uint64 `json:"pools_total,omitempty"`
+}
+
+// Sections of the STATS reply in the order they appear.
+const (
+	sectionPools = iota
+	sectionPool
+	sectionThreads
+	sectionQueue
+	sectionMemory
+	sectionEnd
+)
+
+// statsParser parses the STATS reply line by line:
+//
+//	POOLS: 1
+//
+//	STATE: VALID PRIMARY
+//	THREADS: live 1  idle 0 max 10 idle-timeout 30
+//	QUEUE: 0 items
+//		STATS 0.000061
+//
+//	MEMSTATS: heap 9.082M mmap 0.000M used 6.902M free 2.184M releasable 0.129M pools 1 pools_used 565.979M pools_total 565.999M
+//	END
+type statsParser struct {
+	stats   Stats
+	section int
+	line    int
+	pools   int
+}
+
+func (p *statsParser) warn(format string, a ...any) {
+	p.stats.Warnings = append(p.stats.Warnings, fmt.Sprintf("line %d: ", p.line)+fmt.Sprintf(format, a...))
+}
+
+func (p *statsParser) pool() *Pool {
+	return &p.stats.Pools[len(p.stats.Pools)-1]
+}
+
+func (p *statsParser) parseLine(line string) {
+	if p.section == sectionEnd {
+		p.warn("unexpected line %q after END", line)
+		return
+	}
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if strings.HasPrefix(line, "\t") {
+		if p.section != sectionQueue {
+			p.warn("unexpected command line %q", line)
+			return
+		}
+		p.parseCommand(line[1:])
+		return
+	}
+	key, value, _ := strings.Cut(line, ": ")
+	switch {
+	case key == "POOLS" && p.section == sectionPools:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			p.warn("invalid pool count %q", value)
+		}
+		p.pools = n
+		p.section = sectionPool
+	case key == "STATE" && p.section >= sectionPool && p.section <= sectionQueue:
+		p.stats.Pools = append(p.stats.Pools, Pool{})
+		p.parseState(value)
+		p.section = sectionThreads
+	case key == "THREADS" && p.section == sectionThreads:
+		p.parseThreads(value)
+		p.section = sectionQueue
+	case key == "QUEUE" && p.section == sectionQueue && p.pool().Queue == nil:
+		p.parseQueue(value)
+	case key == "MEMSTATS" && p.section >= sectionPool && p.section <= sectionQueue:
+		p.parseMemory(value)
+		p.section = sectionMemory
+	case line == "END" && p.section >= sectionPool:
+		p.section = sectionEnd
+	default:
+		p.warn("unknown line %q", line)
+	}
+}
+
+func (p *statsParser) parseState(value string) {
+	pool := p.pool()
+	for _, word := range strings.Fields(value) {
+		switch word {
+		case StateInvalid, StateValid, StateExit:
+			pool.State = word
+		case "PRIMARY":
+			pool.Primary = true
+		default:
+			p.warn("unknown pool state %q", word)
+		}
+	}
+}
+
+func (p *statsParser) parseThreads(value string) {
+	threads := &p.pool().Threads
+	fields := strings.Fields(value)
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			p.warn("missing value of thread stat %q", fields[i])
+			break
+		}
+		n, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			p.warn("invalid value of thread stat %q: %q", fields[i], fields[i+1])
+			continue
+		}
+		switch fields[i] {
+		case "live":
+			threads.Live = &n
+		case "idle":
+			threads.Idle = &n
+		case "max":
+			threads.Max = &n
+		case "idle-timeout":
+			threads.IdleTimeout = &n
+		default:
+			p.warn("unknown thread stat %q", fields[i])
+		}
+	}
+}
+
+// parseQueue parses the queue line. The wait times are printed for
+// the bulk and the single queues separately, so they're merged.
+func (p *statsParser) parseQueue(value string) {
+	queue := &Queue{}
+	p.pool().Queue = queue
+	fields := strings.Fields(value)
+	if len(fields) < 2 || fields[1] != "items" {
+		p.warn("invalid queue %q", value)
+		return
+	}
+	var err error
+	if queue.Length, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		p.warn("invalid queue length %q", fields[0])
+	}
+	var (
+		waits   int
+		avgWait float64
+	)
+	queue.MinWait = math.Inf(1)
+	for i := 2; i < len(fields); i++ {
+		if fields[i] == "(INVALID" && i+2 < len(fields) && fields[i+1] == "timestamps:" {
+			n, err := strconv.ParseInt(strings.TrimSuffix(fields[i+2], ")"), 10, 64)
+			if err != nil {
+				p.warn("invalid queue invalid timestamp count %q", fields[i+2])
+			}
+			queue.InvalidTimestamps += n
+			i += 2
+			continue
+		}
+		if i+1 == len(fields) {
+			p.warn("missing value of queue stat %q", fields[i])
+			break
+		}
+		f, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			p.warn("invalid value of queue stat %q: %q", fields[i], fields[i+1])
+			i++
+			continue
+		}
+		switch fields[i] {
+		case "min_wait:":
+			queue.MinWait = min(queue.MinWait, f)
+		case "max_wait:":
+			queue.MaxWait = max(queue.MaxWait, f)
+		case "avg_wait:":
+			avgWait += f
+			waits++
+		default:
+			p.warn("unknown queue stat %q", fields[i])
+		}
+		i++
+	}
+	if math.IsInf(queue.MinWait, 1) {
+		queue.MinWait = 0
+	}
+	if waits > 0 {
+		queue.AvgWait = avgWait / float64(waits)
+	}
+}
+
+func (p *statsParser) parseCommand(value string) {
+	fields := strings.SplitN(value, " ", 3)
+	if len(fields) < 2 {
+		p.warn("invalid command %q", value)
+		return
+	}
+	elapsed, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		p.warn("invalid command elapsed time %q", fields[1])
+		return
+	}
+	cmd := Command{
+		Name:    fields[0],
+		Elapsed: elapsed,
+	}
+	if len(fields) == 3 {
+		cmd.File = strings.TrimSpace(fields[2])
+	}
+	pool := p.pool()
+	pool.Commands = append(pool.Commands, cmd)
+}
+
+func (p *statsParser) parseMemory(value string) {
+	memory := &p.stats.Memory
+	fields := strings.Fields(value)
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			p.warn("missing value of memory stat %q", fields[i])
+			break
+		}
+		name, value := fields[i], fields[i+1]
+		if name == "pools" {
+			// The number of memory pools.
+			continue
+		}
+		var n *uint64
+		if value != "N/A" {
+			f, err := strconv.ParseFloat(strings.TrimSuffix(value, "M"), 64)
+			if err != nil || !strings.HasSuffix(value, "M") {
+				p.warn("invalid value of memory stat %q: %q", name, value)
+				continue
+			}
+			n = new(uint64)
+			*n = uint64(f * 1024 * 1024)
+		}
+		switch name {
+		case "heap":
+			memory.Heap = n
+		case "mmap":
+			memory.Mmap = n
+		case "used":
+			memory.Used = n
+		case "free":
+			memory.Free = n
+		case "releasable":
+			memory.Releasable = n
+		case "pools_used":
+			memory.PoolsUsed = n
+		case "pools_total":
+			memory.PoolsTotal = n
+		default:
+			p.warn("unknown memory stat %q", name)
+		}
+	}
+}
+
+// ParseStats parses the reply to the STATS command.
+// The lines it doesn't recognize are reported as warnings.
+func ParseStats(b []byte) (*Stats, error) {
+	s := strings.TrimSuffix(string(b), "\n")
+	if !strings.HasSuffix(s, "END") {
+		return nil, fmt.Errorf("%w %q", ErrUnexpectedReply, b)
+	}
+	p := &statsParser{}
+	for _, line := range strings.Split(s, "\n") {
+		p.line++
+		p.parseLine(strings.TrimRight(line, "\r"))
+	}
+	if p.section != sectionEnd {
+		p.warn("missing END")
+	}
+	if len(p.stats.Pools) != p.pools {
+		p.warn("found %d pools; want %d", len(p.stats.Pools), p.pools)
+	}
+	return &p.stats, nil
+}
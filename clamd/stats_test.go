@@ -0,0 +1,56 @@
+package clamd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseStats(t *testing.T) {
+	files, err := filepath.Glob("testdata/stats-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			in, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := ParseStats(in)
+			if err != nil {
+				t.Fatalf("ParseStats() = _, %v; want nil", err)
+			}
+			got, err := json.MarshalIndent(stats, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			outFile := strings.TrimSuffix(file, ".txt") + ".json"
+			if _, err := os.Stat(outFile); err == nil {
+				want, err := os.ReadFile(outFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("ParseStats() = %s; want %s", got, want)
+				}
+			} else {
+				if err = os.WriteFile(outFile, got, 0666); err != nil {
+					t.Fatal(err)
+				}
+				t.Logf("wrote %s golden master", outFile)
+			}
+		})
+	}
+}
+
+func TestParseStats_Invalid(t *testing.T) {
+	if _, err := ParseStats([]byte("UNKNOWN COMMAND")); !errors.Is(err, ErrUnexpectedReply) {
+		t.Errorf("ParseStats() = _, %v; want ErrUnexpectedReply", err)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// healthHandler checks the ClamAV daemon of the module given in the module query parameter
+// is up and its database isn't older than the module database max age.
+func healthHandler(w http.ResponseWriter, r *http.Request, modules *moduleSet, logger *slog.Logger) {
+	name := r.URL.Query().Get("module")
+	if name == "" {
+		name = defaultModule
+	}
+	_, exporter, ok := modules.get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", name), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+	if err := exporter.Health(ctx); err != nil {
+		logger.Debug("ClamAV daemon is unhealthy", "module", name, "err", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "Healthy")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+	"github.com/sergeymakinen/clamav_exporter/v2/config"
+)
+
+func TestHealthHandler(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	modules, err := newModuleSet("", &config.Module{
+		Address: "tcp://" + l.Addr().String(),
+		Timeout: defaults.Timeout,
+	}, 0, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("newModuleSet() = _, %v; want nil", err)
+	}
+	tests := []struct {
+		url  string
+		code int
+	}{
+		{url: "/-/healthy?module=foo", code: http.StatusBadRequest},
+		{url: "/-/healthy", code: http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			healthHandler(rec, httptest.NewRequest(http.MethodGet, test.url, nil), modules, promslog.NewNopLogger())
+			if rec.Code != test.code {
+				t.Errorf("healthHandler() status = %d; want %d", rec.Code, test.code)
+			}
+		})
+	}
+}
@@ -4,24 +4,54 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
+	"slices"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	promconfig "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/common/version"
 	"github.com/prometheus/exporter-toolkit/web"
 	webflag "github.com/prometheus/exporter-toolkit/web/kingpinflag"
+	"github.com/sergeymakinen/clamav_exporter/v2/clamd"
+	"github.com/sergeymakinen/clamav_exporter/v2/config"
 	"github.com/sergeymakinen/clamav_exporter/v2/exporter"
 )
 
 func main() {
 	var (
-		address      = kingpin.Flag("clamav.address", "ClamAV daemon socket address.").PlaceHolder(`"tcp://127.0.0.1:3310"`).Default("tcp://127.0.0.1:3310").URL()
+		addressSet   bool
+		configFile   = kingpin.Flag("config.file", "Exporter configuration file.").String()
+		address      = kingpin.Flag("clamav.address", "ClamAV daemon socket address. Read from the clamav.config-file file if not set.").PlaceHolder(`"tcp://127.0.0.1:3310"`).IsSetByUser(&addressSet).Default("tcp://127.0.0.1:3310").URL()
 		timeout      = kingpin.Flag("clamav.timeout", "ClamAV daemon socket timeout.").Default("5s").Duration()
 		retries      = kingpin.Flag("clamav.retries", "ClamAV daemon socket connect retries.").Default("0").Int()
+		backoff      = kingpin.Flag("clamav.retry-backoff", "Delay before the first retry of a ClamAV daemon scrape, doubled after every failed retry.").Default("100ms").Duration()
+		maxBackoff   = kingpin.Flag("clamav.retry-max-backoff", "Maximum delay between retries of a ClamAV daemon scrape.").Default("5s").Duration()
+		tlsCAFile    = kingpin.Flag("clamav.tls.ca-file", "CA certificate file to verify the ClamAV daemon certificate with.").String()
+		tlsCertFile  = kingpin.Flag("clamav.tls.cert-file", "Client certificate file to authenticate to the ClamAV daemon with.").String()
+		tlsKeyFile   = kingpin.Flag("clamav.tls.key-file", "Client key file to authenticate to the ClamAV daemon with.").String()
+		tlsName      = kingpin.Flag("clamav.tls.server-name", "Server name to verify the ClamAV daemon certificate against.").String()
+		tlsInsecure  = kingpin.Flag("clamav.tls.insecure-skip-verify", "Don't verify the ClamAV daemon certificate.").Bool()
+		maxAge       = kingpin.Flag("clamav.max-result-age", "Maximum age of ClamAV daemon stats to reuse between scrapes. 0 shares only in-flight scrapes.").Default("0s").Duration()
+		persistent   = kingpin.Flag("clamav.persistent-session", "Keep the ClamAV daemon session open between scrapes.").Bool()
+		pollInterval = kingpin.Flag("clamav.poll-interval", "Interval to scrape ClamAV daemons in the background at, serving cached stats on scrapes. 0 disables polling.").Default("0s").Duration()
+		dbMaxAge     = kingpin.Flag("clamav.db-max-age", "Maximum age of the ClamAV Virus Database before it's reported as stale. 0 disables the check.").Default("0s").Duration()
+		scanProbe    = kingpin.Flag("clamav.scan-probe", "Scan the EICAR test file to check the ClamAV daemon detects viruses.").Bool()
+		published    = kingpin.Flag("clamav.published-versions", "Compare the ClamAV Virus Database versions with the published ones.").Bool()
+		record       = kingpin.Flag("clamav.published-record", "DNS TXT record to get the published ClamAV versions from.").Default(exporter.DefaultPublishedRecord).String()
+		resolver     = kingpin.Flag("clamav.dns-resolver", "DNS resolver address to resolve the published ClamAV versions with. The system resolver by default.").PlaceHolder(`"127.0.0.1:53"`).String()
+		clamdConfig  = kingpin.Flag("clamav.config-file", "ClamAV daemon configuration file to export the settings and read the socket address from.").PlaceHolder(`"/etc/clamav/clamd.conf"`).String()
+		pidFile      = kingpin.Flag("clamav.pid-file", "ClamAV daemon PID file to export process stats for. Read from the clamav.config-file file if neither it nor clamav.process-name is set.").PlaceHolder(`"/run/clamav/clamd.pid"`).String()
+		processName  = kingpin.Flag("clamav.process-name", "ClamAV daemon process name to export process stats for if clamav.pid-file isn't set.").PlaceHolder(`"clamd"`).String()
+		databaseDir  = kingpin.Flag("clamav.database-dir", "ClamAV Virus Database directory to export database file stats from.").PlaceHolder(`"/var/lib/clamav"`).String()
+		clamdLog     = kingpin.Flag("clamav.log-file", "ClamAV daemon log file to export detection and event stats from.").PlaceHolder(`"/var/log/clamav/clamav.log"`).String()
+		maxSigs      = kingpin.Flag("clamav.log-max-signatures", "Maximum number of distinct signatures to export detections for.").Default("100").Int()
+		freshclamLog = kingpin.Flag("freshclam.log-file", "freshclam log file to export database update stats from.").PlaceHolder(`"/var/log/clamav/freshclam.log"`).String()
 		toolkitFlags = webflag.AddFlags(kingpin.CommandLine, ":9906")
 		metricsPath  = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
 	)
@@ -37,14 +67,102 @@ func main() {
 	logger.Info("Build context", "context", version.BuildContext())
 
 	prometheus.MustRegister(versioncollector.NewCollector("clamav_exporter"))
-	exporter, err := exporter.New(*address, *timeout, *retries, logger)
+	if *databaseDir != "" {
+		prometheus.MustRegister(
+			exporter.NewDatabaseCollector(*databaseDir, logger),
+			exporter.NewSignatureCollector(*databaseDir, logger),
+			exporter.NewFreshclamDatCollector(filepath.Join(*databaseDir, "freshclam.dat"), logger),
+		)
+	}
+	var clamdCfg *clamd.Config
+	if *clamdConfig != "" {
+		var err error
+		if clamdCfg, err = clamd.ReadConfig(*clamdConfig); err != nil {
+			if !addressSet {
+				logger.Error("Error reading ClamAV daemon config", "file", *clamdConfig, "err", err)
+				os.Exit(1)
+			}
+			logger.Warn("Error reading ClamAV daemon config", "file", *clamdConfig, "err", err)
+		}
+	}
+	if clamdCfg != nil && !addressSet {
+		var err error
+		if *address, err = clamdCfg.Address(); err != nil {
+			logger.Error("Error getting ClamAV daemon address from config", "file", *clamdConfig, "err", err)
+			os.Exit(1)
+		}
+		logger.Info("Using ClamAV daemon address from config", "file", *clamdConfig, "address", (*address).String())
+	}
+	defaults := &config.Module{
+		Address:           (*address).String(),
+		Timeout:           model.Duration(*timeout),
+		Retries:           *retries,
+		RetryBackoff:      model.Duration(*backoff),
+		MaxRetryBackoff:   model.Duration(*maxBackoff),
+		MaxResultAge:      model.Duration(*maxAge),
+		DBMaxAge:          model.Duration(*dbMaxAge),
+		PersistentSession: *persistent,
+		PublishedRecord:   *record,
+		DNSResolver:       *resolver,
+	}
+	if *tlsCAFile != "" || *tlsCertFile != "" || *tlsKeyFile != "" || *tlsName != "" || *tlsInsecure {
+		defaults.TLSConfig = &promconfig.TLSConfig{
+			CAFile:             *tlsCAFile,
+			CertFile:           *tlsCertFile,
+			KeyFile:            *tlsKeyFile,
+			ServerName:         *tlsName,
+			InsecureSkipVerify: *tlsInsecure,
+		}
+		if err := defaults.TLSConfig.Validate(); err != nil {
+			logger.Error("Invalid TLS settings", "err", err)
+			os.Exit(1)
+		}
+	}
+	if *scanProbe || *published {
+		defaults.Collectors = slices.Clone(exporter.DefaultCollectors)
+		if *scanProbe {
+			defaults.Collectors = append(defaults.Collectors, exporter.CollectorScan)
+		}
+		if *published {
+			defaults.Collectors = append(defaults.Collectors, exporter.CollectorPublished)
+		}
+	}
+	if *clamdConfig != "" {
+		prometheus.MustRegister(exporter.NewClamdConfigCollector(*clamdConfig, logger))
+	}
+	if clamdCfg != nil && *pidFile == "" && *processName == "" {
+		*pidFile, _ = clamdCfg.Value("PidFile")
+	}
+	if *pidFile != "" || *processName != "" {
+		prometheus.MustRegister(exporter.NewProcessCollector(*pidFile, *processName, logger))
+	}
+	if *clamdLog != "" {
+		prometheus.MustRegister(exporter.NewClamdLogCollector(*clamdLog, *maxSigs, logger))
+	}
+	if *freshclamLog != "" {
+		prometheus.MustRegister(exporter.NewFreshclamCollector(*freshclamLog, logger))
+	}
+	modules, err := newModuleSet(*configFile, defaults, *pollInterval, logger)
 	if err != nil {
-		logger.Error("Error creating the exporter", "err", err)
+		logger.Error("Error loading config", "err", err)
 		os.Exit(1)
 	}
-	prometheus.MustRegister(exporter)
+	configReloadSuccess.Set(1)
+	configReloadSeconds.SetToCurrentTime()
+	watchReloadSignal(modules, logger)
 
-	http.Handle(*metricsPath, promhttp.Handler())
+	http.Handle(*metricsPath, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metricsHandler(w, r, modules)
+	})))
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		probeHandler(w, r, modules, logger)
+	})
+	http.HandleFunc("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
+		healthHandler(w, r, modules, logger)
+	})
+	http.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+		reloadHandler(w, r, modules, logger)
+	})
 	if *metricsPath != "/" {
 		landingConfig := web.LandingConfig{
 			Name:        "ClamAV Exporter",
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrapeTimeoutOffset is subtracted from the Prometheus scrape timeout,
+// so there's time left to send the metrics.
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
+// scrapeContext returns the context of the scrape request. It's done when
+// the client disconnects or the Prometheus scrape timeout is about to pass.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	s := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if s == "" {
+		return context.WithCancel(r.Context())
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// metricsHandler exports the exporter's own metrics along with the stats of
+// the ClamAV daemon of the module given in the module query parameter.
+func metricsHandler(w http.ResponseWriter, r *http.Request, modules *moduleSet) {
+	name := r.URL.Query().Get("module")
+	if name == "" {
+		name = defaultModule
+	}
+	module, exporter, ok := modules.get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", name), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(module.Labels, registry).MustRegister(exporter.WithContext(ctx))
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, registry}
+	promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScrapeContext(t *testing.T) {
+	tests := []struct {
+		timeout string
+		want    time.Duration
+	}{
+		{timeout: "", want: 0},
+		{timeout: "foo", want: 0},
+		{timeout: "-1", want: 0},
+		{timeout: "10", want: 10*time.Second - scrapeTimeoutOffset},
+		{timeout: "0.25", want: 250 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.timeout, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if test.timeout != "" {
+				r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", test.timeout)
+			}
+			ctx, cancel := scrapeContext(r)
+			start := time.Now()
+			deadline, ok := ctx.Deadline()
+			if test.want == 0 {
+				if ok {
+					t.Errorf("scrapeContext() deadline = %s; want none", deadline)
+				}
+			} else if d := deadline.Sub(start); !ok || d > test.want || d < test.want-time.Second {
+				t.Errorf("scrapeContext() timeout = %s; want %s", d, test.want)
+			}
+			cancel()
+			if ctx.Err() != context.Canceled {
+				t.Errorf("ctx.Err() = %v; want context.Canceled", ctx.Err())
+			}
+		})
+	}
+}
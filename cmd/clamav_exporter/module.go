@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	promconfig "github.com/prometheus/common/config"
+	"github.com/sergeymakinen/clamav_exporter/v2/config"
+	"github.com/sergeymakinen/clamav_exporter/v2/exporter"
+)
+
+const defaultModule = "default"
+
+// loadModules returns the modules from the configuration file (if any) merged
+// with the default module set by flags. The configuration file may override
+// the default module. Modules without an address use the default module one.
+func loadModules(filename string, defaults *config.Module) (map[string]*config.Module, error) {
+	modules := map[string]*config.Module{
+		defaultModule: defaults,
+	}
+	if filename == "" {
+		return modules, nil
+	}
+	cfg, err := config.LoadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for name, module := range cfg.Modules {
+		if module.Address == "" {
+			module.Address = defaults.Address
+		}
+		modules[name] = module
+	}
+	return modules, nil
+}
+
+// exporterSettings returns the exporter settings for the module. A non-nil address overrides the module one.
+func exporterSettings(module *config.Module, address *url.URL) (*url.URL, []exporter.Option, error) {
+	if address == nil {
+		var err error
+		if address, err = config.ParseAddress(module.Address); err != nil {
+			return nil, nil, err
+		}
+	}
+	opts := []exporter.Option{
+		exporter.WithRetryBackoff(time.Duration(module.RetryBackoff), time.Duration(module.MaxRetryBackoff)),
+	}
+	if module.MaxResultAge > 0 {
+		opts = append(opts, exporter.WithMaxResultAge(time.Duration(module.MaxResultAge)))
+	}
+	if module.DBMaxAge > 0 {
+		opts = append(opts, exporter.WithDBMaxAge(time.Duration(module.DBMaxAge)))
+	}
+	if module.PublishedRecord != "" || module.DNSResolver != "" {
+		opts = append(opts, exporter.WithPublishedRecord(module.PublishedRecord, module.DNSResolver))
+	}
+	if module.PersistentSession {
+		opts = append(opts, exporter.WithPersistentSession())
+	}
+	if module.TLSConfig != nil {
+		tlsConfig, err := promconfig.NewTLSConfig(module.TLSConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, exporter.WithTLSConfig(tlsConfig))
+	}
+	if len(module.Collectors) > 0 {
+		opts = append(opts, exporter.WithCollectors(module.Collectors...))
+	}
+	return address, opts, nil
+}
+
+// newExporter returns an exporter for the module. A non-nil address overrides the module one.
+func newExporter(module *config.Module, address *url.URL, logger *slog.Logger) (*exporter.Exporter, error) {
+	address, opts, err := exporterSettings(module, address)
+	if err != nil {
+		return nil, err
+	}
+	return exporter.New(address, time.Duration(module.Timeout), module.Retries, logger, opts...)
+}
+
+// moduleSet holds the modules along with their long-lived exporters.
+type moduleSet struct {
+	filename     string
+	defaults     *config.Module
+	pollInterval time.Duration
+	logger       *slog.Logger
+
+	reloadMu  sync.Mutex
+	mu        sync.RWMutex
+	modules   map[string]*config.Module
+	exporters map[string]*exporter.Exporter
+	// stopPolls stops polling of the exporters by module.
+	stopPolls map[string]context.CancelFunc
+}
+
+// get returns the module with its exporter by name.
+func (s *moduleSet) get(name string) (*config.Module, *exporter.Exporter, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	module, ok := s.modules[name]
+	return module, s.exporters[name], ok
+}
+
+// reload re-reads the configuration file and updates the exporters.
+// Exporters of the modules that still exist are reconfigured in place, so they keep their state.
+// If the configuration can't be loaded, the modules are left unchanged: all the exporters
+// are validated and built before any of them is reconfigured or starts polling.
+func (s *moduleSet) reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	modules, err := loadModules(s.filename, s.defaults)
+	if err != nil {
+		return err
+	}
+	type settings struct {
+		address *url.URL
+		opts    []exporter.Option
+	}
+	all := make(map[string]settings, len(modules))
+	exporters := make(map[string]*exporter.Exporter, len(modules))
+	for name, module := range modules {
+		address, opts, err := exporterSettings(module, nil)
+		if err != nil {
+			return err
+		}
+		all[name] = settings{address: address, opts: opts}
+		// New validates the settings the same way Configure does,
+		// so the exporters of the existing modules are built just to check them.
+		e, err := exporter.New(address, time.Duration(module.Timeout), module.Retries, s.logger.With("module", name), opts...)
+		if err != nil {
+			return err
+		}
+		if _, ok := s.exporters[name]; !ok {
+			exporters[name] = e
+		}
+	}
+	stopPolls := make(map[string]context.CancelFunc, len(modules))
+	for name, module := range modules {
+		if e, ok := s.exporters[name]; ok {
+			if err := e.Configure(all[name].address, time.Duration(module.Timeout), module.Retries, all[name].opts...); err != nil {
+				// Unreachable as the settings are already validated.
+				return err
+			}
+			exporters[name] = e
+			stopPolls[name] = s.stopPolls[name]
+			continue
+		}
+		if s.pollInterval > 0 {
+			ctx, cancel := context.WithCancel(context.Background())
+			exporters[name].StartPolling(ctx, s.pollInterval)
+			stopPolls[name] = cancel
+		}
+	}
+	for name, stop := range s.stopPolls {
+		if _, ok := modules[name]; !ok && stop != nil {
+			stop()
+		}
+	}
+	for name, e := range s.exporters {
+		if _, ok := modules[name]; !ok {
+			e.Close()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modules = modules
+	s.exporters = exporters
+	s.stopPolls = stopPolls
+	return nil
+}
+
+// newModuleSet returns the modules loaded from the configuration file
+// and the default module set by flags. If pollInterval is positive,
+// the exporters poll their ClamAV daemons in the background.
+func newModuleSet(filename string, defaults *config.Module, pollInterval time.Duration, logger *slog.Logger) (*moduleSet, error) {
+	s := &moduleSet{
+		filename:     filename,
+		defaults:     defaults,
+		pollInterval: pollInterval,
+		logger:       logger,
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
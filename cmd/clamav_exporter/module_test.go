@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/promslog"
+	"github.com/sergeymakinen/clamav_exporter/v2/config"
+)
+
+func TestModuleSet_reload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig := func(s string) {
+		if err := os.WriteFile(filename, []byte(s), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("modules:\n  foo:\n    timeout: 1s\n")
+	modules, err := newModuleSet(filename, defaults, 0, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("newModuleSet() = _, %v; want nil", err)
+	}
+	_, foo, ok := modules.get("foo")
+	if !ok {
+		t.Fatal("get(foo) = _, _, false; want true")
+	}
+
+	writeConfig("modules:\n  foo:\n    timeout: 2s\n  bar:\n    retries: 1\n")
+	if err = modules.reload(); err != nil {
+		t.Fatalf("reload() = %v; want nil", err)
+	}
+	module, e, ok := modules.get("foo")
+	if !ok {
+		t.Fatal("get(foo) = _, _, false; want true")
+	}
+	if e != foo {
+		t.Error("get(foo) returned a new exporter; want the reconfigured one")
+	}
+	if module.Timeout.String() != "2s" {
+		t.Errorf("get(foo).Timeout = %s; want 2s", module.Timeout)
+	}
+	if _, _, ok = modules.get("bar"); !ok {
+		t.Error("get(bar) = _, _, false; want true")
+	}
+
+	writeConfig("modules:\n  foo:\n    timeout: 3s\n  baz:\n    timeout: 1s\n  qux:\n    retries: -1\n")
+	if err = modules.reload(); err == nil {
+		t.Error("reload() = nil; want error")
+	}
+	if _, _, ok = modules.get("bar"); !ok {
+		t.Error("get(bar) = _, _, false after a failed reload; want true")
+	}
+	if _, _, ok = modules.get("baz"); ok {
+		t.Error("get(baz) = _, _, true after a failed reload; want false")
+	}
+	if module, e, _ = modules.get("foo"); e != foo || module.Timeout.String() != "2s" {
+		t.Errorf("get(foo).Timeout = %s after a failed reload; want 2s", module.Timeout)
+	}
+}
+
+func TestReloadHandler_MethodNotAllowed(t *testing.T) {
+	modules, err := newModuleSet("", defaults, 0, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("newModuleSet() = _, %v; want nil", err)
+	}
+	rec := httptest.NewRecorder()
+	reloadHandler(rec, httptest.NewRequest(http.MethodGet, "/-/reload", nil), modules, promslog.NewNopLogger())
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("reloadHandler() status = %d; want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+var defaults = &config.Module{
+	Address: "tcp://127.0.0.1:3310",
+	Timeout: model.Duration(time.Second),
+}
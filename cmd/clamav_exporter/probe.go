@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sergeymakinen/clamav_exporter/v2/config"
+)
+
+// probeHandler scrapes the ClamAV daemon given in the target query parameter
+// using a fresh registry, so a single exporter can serve many daemons.
+// The module query parameter selects the settings to use.
+func probeHandler(w http.ResponseWriter, r *http.Request, modules *moduleSet, logger *slog.Logger) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "Target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	address, err := config.ParseAddress(target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid target %q: %v", target, err), http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("module")
+	if name == "" {
+		name = defaultModule
+	}
+	module, _, ok := modules.get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", name), http.StatusBadRequest)
+		return
+	}
+	exporter, err := newExporter(module, address, logger.With("target", target, "module", name))
+	if err != nil {
+		logger.Error("Error creating the exporter", "target", target, "module", name, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The exporter lives for a single probe, so a persistent session isn't kept.
+	defer exporter.Close()
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	prometheus.WrapRegistererWith(module.Labels, registry).MustRegister(exporter.WithContext(ctx))
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestProbeHandler_BadRequest(t *testing.T) {
+	modules, err := newModuleSet("", defaults, 0, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("newModuleSet() = _, %v; want nil", err)
+	}
+	tests := []string{
+		"/probe",
+		"/probe?target=http://127.0.0.1:3310",
+		"/probe?target=tcp://127.0.0.1:3310&module=foo",
+	}
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			probeHandler(rec, httptest.NewRequest(http.MethodGet, test, nil), modules, promslog.NewNopLogger())
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("probeHandler() status = %d; want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
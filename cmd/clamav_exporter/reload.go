@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	configReloadSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clamav_exporter",
+		Name:      "config_last_reload_successful",
+		Help:      "ClamAV exporter config loaded successfully.",
+	})
+	configReloadSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "clamav_exporter",
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful configuration reload.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(configReloadSuccess, configReloadSeconds)
+}
+
+// reloadModules reloads the modules and updates the reload metrics.
+func reloadModules(modules *moduleSet, logger *slog.Logger) error {
+	if err := modules.reload(); err != nil {
+		configReloadSuccess.Set(0)
+		logger.Error("Error reloading config", "err", err)
+		return err
+	}
+	configReloadSuccess.Set(1)
+	configReloadSeconds.SetToCurrentTime()
+	logger.Info("Reloaded config file")
+	return nil
+}
+
+// watchReloadSignal reloads the modules on SIGHUP.
+func watchReloadSignal(modules *moduleSet, logger *slog.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadModules(modules, logger)
+		}
+	}()
+}
+
+// reloadHandler reloads the modules on POST requests.
+func reloadHandler(w http.ResponseWriter, r *http.Request, modules *moduleSet, logger *slog.Logger) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "This endpoint requires a POST request", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := reloadModules(modules, logger); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload config: %s", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,173 @@
+// Package config provides the exporter configuration file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/sergeymakinen/clamav_exporter/v2/exporter"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultModule is the default module configuration.
+var DefaultModule = Module{
+	Timeout:         model.Duration(5 * time.Second),
+	RetryBackoff:    model.Duration(exporter.DefaultRetryBackoff),
+	MaxRetryBackoff: model.Duration(exporter.DefaultMaxRetryBackoff),
+}
+
+// Config is the exporter configuration.
+type Config struct {
+	Modules map[string]*Module `yaml:"modules"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	for name, module := range c.Modules {
+		if name == "" {
+			return errors.New("empty module name")
+		}
+		if module == nil {
+			return fmt.Errorf("empty module %q", name)
+		}
+		if err := module.Validate(); err != nil {
+			return fmt.Errorf("invalid module %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Module is a named set of ClamAV daemon settings.
+type Module struct {
+	// Address is the ClamAV daemon socket address. Empty means the one
+	// set by a flag or a probe target.
+	Address           string            `yaml:"address,omitempty"`
+	Timeout           model.Duration    `yaml:"timeout,omitempty"`
+	Retries           int               `yaml:"retries,omitempty"`
+	RetryBackoff      model.Duration    `yaml:"retry_backoff,omitempty"`
+	MaxRetryBackoff   model.Duration    `yaml:"max_retry_backoff,omitempty"`
+	MaxResultAge      model.Duration    `yaml:"max_result_age,omitempty"`
+	DBMaxAge          model.Duration    `yaml:"db_max_age,omitempty"`
+	PersistentSession bool              `yaml:"persistent_session,omitempty"`
+	PublishedRecord   string            `yaml:"published_record,omitempty"`
+	DNSResolver       string            `yaml:"dns_resolver,omitempty"`
+	TLSConfig         *config.TLSConfig `yaml:"tls_config,omitempty"`
+	Collectors        []string          `yaml:"collectors,omitempty"`
+	Labels            map[string]string `yaml:"labels,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (m *Module) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*m = DefaultModule
+	type plain Module
+	return unmarshal((*plain)(m))
+}
+
+// Validate checks the module settings.
+func (m *Module) Validate() error {
+	if m.Address != "" {
+		if _, err := ParseAddress(m.Address); err != nil {
+			return fmt.Errorf("invalid address %q: %w", m.Address, err)
+		}
+	}
+	if m.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout %s", m.Timeout)
+	}
+	if m.Retries < 0 {
+		return fmt.Errorf("invalid retry count %d", m.Retries)
+	}
+	if m.RetryBackoff < 0 || m.MaxRetryBackoff < m.RetryBackoff {
+		return fmt.Errorf("invalid retry backoff %s-%s", m.RetryBackoff, m.MaxRetryBackoff)
+	}
+	if m.MaxResultAge < 0 {
+		return fmt.Errorf("invalid max result age %s", m.MaxResultAge)
+	}
+	if m.DBMaxAge < 0 {
+		return fmt.Errorf("invalid database max age %s", m.DBMaxAge)
+	}
+	if m.DNSResolver != "" {
+		if _, _, err := net.SplitHostPort(m.DNSResolver); err != nil {
+			return fmt.Errorf("invalid DNS resolver address %q: %w", m.DNSResolver, err)
+		}
+	}
+	if m.TLSConfig != nil {
+		if err := m.TLSConfig.Validate(); err != nil {
+			return fmt.Errorf("invalid TLS config: %w", err)
+		}
+	}
+	for _, name := range m.Collectors {
+		if !slices.Contains(exporter.Collectors, name) {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	for name := range m.Labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
+
+// ParseAddress parses a ClamAV daemon socket address.
+// Addresses without a scheme are treated as TCP addresses.
+// The tls and tcp+tls schemes are TCP addresses connected to using TLS.
+func ParseAddress(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "tcp://" + s
+	}
+	address, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch address.Scheme {
+	case "tcp", "tcp4", "tcp6", "tls", "tcp+tls":
+		if address.Host == "" {
+			return nil, errors.New("missing host")
+		}
+	case "unix":
+		if address.Path == "" {
+			return nil, errors.New("missing socket path")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", address.Scheme)
+	}
+	return address, nil
+}
+
+// Load parses the YAML input s into a Config.
+func Load(s string) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict([]byte(s), cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadFile parses the given YAML file into a Config.
+func LoadFile(filename string) (*Config, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Load(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parsing YAML file %s: %w", filename, err)
+	}
+	for _, module := range cfg.Modules {
+		module.TLSConfig.SetDirectory(filepath.Dir(filename))
+	}
+	return cfg, nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestLoadFile(t *testing.T) {
+	cfg, err := LoadFile("testdata/good.yml")
+	if err != nil {
+		t.Fatalf("LoadFile() = _, %v; want nil", err)
+	}
+	module := cfg.Modules["default"]
+	if module == nil {
+		t.Fatal("Modules[default] = nil; want non-nil")
+	}
+	if module.Timeout != model.Duration(5*time.Second) {
+		t.Errorf("Modules[default].Timeout = %s; want 5s", module.Timeout)
+	}
+	module = cfg.Modules["remote"]
+	if module == nil {
+		t.Fatal("Modules[remote] = nil; want non-nil")
+	}
+	if module.Timeout != model.Duration(10*time.Second) {
+		t.Errorf("Modules[remote].Timeout = %s; want 10s", module.Timeout)
+	}
+	if module.Retries != 2 {
+		t.Errorf("Modules[remote].Retries = %d; want 2", module.Retries)
+	}
+	if module.RetryBackoff != model.Duration(time.Second) || module.MaxRetryBackoff != model.Duration(10*time.Second) {
+		t.Errorf("Modules[remote].RetryBackoff, MaxRetryBackoff = %s, %s; want 1s, 10s", module.RetryBackoff, module.MaxRetryBackoff)
+	}
+	if module.MaxResultAge != model.Duration(10*time.Second) {
+		t.Errorf("Modules[remote].MaxResultAge = %s; want 10s", module.MaxResultAge)
+	}
+	if module.DBMaxAge != model.Duration(24*time.Hour) {
+		t.Errorf("Modules[remote].DBMaxAge = %s; want 1d", module.DBMaxAge)
+	}
+	if !module.PersistentSession {
+		t.Error("Modules[remote].PersistentSession = false; want true")
+	}
+	if want := filepath.Join("testdata", "ca.pem"); module.TLSConfig == nil || module.TLSConfig.CAFile != want {
+		t.Errorf("Modules[remote].TLSConfig = %+v; want CAFile = %q", module.TLSConfig, want)
+	}
+	if module.PublishedRecord != "current.cvd.example.com" || module.DNSResolver != "127.0.0.1:53" {
+		t.Errorf("Modules[remote].PublishedRecord, DNSResolver = %q, %q; want current.cvd.example.com, 127.0.0.1:53", module.PublishedRecord, module.DNSResolver)
+	}
+	if len(module.Collectors) != 3 {
+		t.Errorf("len(Modules[remote].Collectors) = %d; want 3", len(module.Collectors))
+	}
+	if module.Labels["env"] != "prod" {
+		t.Errorf("Modules[remote].Labels[env] = %q; want prod", module.Labels["env"])
+	}
+}
+
+func TestLoadFile_Invalid(t *testing.T) {
+	tests := []struct {
+		file string
+		err  string
+	}{
+		{file: "testdata/invalid-collector.yml", err: `invalid module "default": unknown collector "foo"`},
+		{file: "testdata/invalid-retries.yml", err: `invalid module "default": invalid retry count -1`},
+		{file: "testdata/invalid-backoff.yml", err: `invalid module "default": invalid retry backoff 2s-1s`},
+		{file: "testdata/invalid-resolver.yml", err: `invalid module "default": invalid DNS resolver address "127.0.0.1": address 127.0.0.1: missing port in address`},
+		{file: "testdata/invalid-address.yml", err: `invalid module "default": invalid address "http://127.0.0.1:3310": unsupported scheme "http"`},
+		{file: "testdata/invalid-label.yml", err: `invalid module "default": invalid label name "__env"`},
+		{file: "testdata/unknown-field.yml", err: "field timeot not found"},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			_, err := LoadFile(test.file)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("LoadFile() = _, %v; want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		wantErr bool
+	}{
+		{address: "tcp://127.0.0.1:3310", want: "tcp://127.0.0.1:3310"},
+		{address: "clamav:3310", want: "tcp://clamav:3310"},
+		{address: "unix:///run/clamav/clamd.ctl", want: "unix:///run/clamav/clamd.ctl"},
+		{address: "tls://clamav:3310", want: "tls://clamav:3310"},
+		{address: "tcp+tls://clamav:3310", want: "tcp+tls://clamav:3310"},
+		{address: "tls://", wantErr: true},
+		{address: "tcp://", wantErr: true},
+		{address: "unix://", wantErr: true},
+		{address: "http://clamav:3310", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			address, err := ParseAddress(test.address)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ParseAddress() = %v, nil; want _, error", address)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAddress() = _, %v; want nil", err)
+			}
+			if got := address.String(); got != test.want {
+				t.Errorf("ParseAddress() = %q; want %q", got, test.want)
+			}
+		})
+	}
+}
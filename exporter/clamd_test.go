@@ -1,13 +1,22 @@
 package exporter
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -15,6 +24,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/promslog"
+	"github.com/sergeymakinen/clamav_exporter/v2/clamd"
 )
 
 func TestExporter_scrapeClamd(t *testing.T) {
@@ -28,12 +38,29 @@ func TestExporter_scrapeClamd(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			exporter, err := New(nil, 0, 0, promslog.NewNopLogger())
+			resp := strings.Split(strings.TrimSuffix(string(in), "\n"), "\n--\n")
+			replies := map[string]string{
+				"PING":    resp[0],
+				"VERSION": resp[1],
+				"STATS":   resp[2],
+			}
+			var opts []Option
+			if len(resp) > 3 {
+				replies["INSTREAM"] = resp[3]
+				opts = append(opts, WithCollectors(append(slices.Clone(DefaultCollectors), CollectorScan)...))
+			}
+			exporter, err := New(serveClamd(t, replies), time.Second, 0, promslog.NewNopLogger(), opts...)
 			if err != nil {
 				t.Fatalf("New() = _, %v; want nil", err)
 			}
-			exporter.scrape = func(e *Exporter) (m metrics, ok bool) {
-				return e.scrapeClamd(bytes.Split(bytes.TrimSuffix(in, []byte("\n")), []byte("\n--\n")))
+			exporter.scrape = func(e *Exporter, ctx context.Context) (m metrics, ok bool) {
+				m, ok = e.scrapeSocket(ctx)
+				// The durations vary between runs.
+				m.Scrape.Duration = 0
+				if m.ScanProbe != nil {
+					m.ScanProbe.Duration = 0
+				}
+				return
 			}
 			outFile := strings.Replace(file, "-socket.txt", "-metrics.txt", 1)
 			if _, err := os.Stat(outFile); err == nil {
@@ -54,6 +81,374 @@ func TestExporter_scrapeClamd(t *testing.T) {
 	}
 }
 
+func TestExporter_scrapeSocket(t *testing.T) {
+	in, err := os.ReadFile("testdata/5-socket.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := strings.Split(strings.TrimSuffix(string(in), "\n"), "\n--\n")
+	address := serveClamd(t, map[string]string{
+		"PING":     resp[0],
+		"VERSION":  resp[1],
+		"STATS":    resp[2],
+		"INSTREAM": resp[3],
+	})
+	out, err := os.ReadFile("testdata/5-metrics.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter, err := New(address, time.Second, 0, promslog.NewNopLogger(), WithCollectors(CollectorVersion, CollectorPools, CollectorScan))
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	metricNames := []string{
+		"clamav_version",
+		"clamav_db_version",
+		"clamav_pool_max_threads",
+		"clamav_scan_probe_success",
+		"clamav_up",
+	}
+	if err = testutil.CollectAndCompare(exporter, bytes.NewReader(out), metricNames...); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+}
+
+func TestExporter_scrapeSocket_DialError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := &url.URL{Scheme: "tcp", Host: l.Addr().String()}
+	l.Close()
+	exporter, err := New(address, time.Second, 1, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	want := `# HELP clamav_scrape_errors_total Number of ClamAV scrape errors by the failed stage.
+# TYPE clamav_scrape_errors_total counter
+clamav_scrape_errors_total{stage="dial"} 2
+clamav_scrape_errors_total{stage="other"} 0
+clamav_scrape_errors_total{stage="parse"} 0
+clamav_scrape_errors_total{stage="ping"} 0
+clamav_scrape_errors_total{stage="read"} 0
+clamav_scrape_errors_total{stage="send"} 0
+# HELP clamav_scrape_response_size_bytes Size of the ClamAV responses of the last scrape in bytes.
+# TYPE clamav_scrape_response_size_bytes gauge
+clamav_scrape_response_size_bytes 0
+# HELP clamav_scrape_retries_total Number of ClamAV scrape retries.
+# TYPE clamav_scrape_retries_total counter
+clamav_scrape_retries_total 1
+# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 0
+`
+	metricNames := []string{
+		"clamav_scrape_errors_total",
+		"clamav_scrape_response_size_bytes",
+		"clamav_scrape_retries_total",
+		"clamav_up",
+	}
+	if err = testutil.CollectAndCompare(exporter, strings.NewReader(want), metricNames...); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+}
+
+func TestExporter_scrapeSocket_NoTimeToRetry(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := &url.URL{Scheme: "tcp", Host: l.Addr().String()}
+	l.Close()
+	exporter, err := New(address, time.Second, 3, promslog.NewNopLogger(), WithRetryBackoff(10*time.Second, 10*time.Second))
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	want := `# HELP clamav_scrape_errors_total Number of ClamAV scrape errors by the failed stage.
+# TYPE clamav_scrape_errors_total counter
+clamav_scrape_errors_total{stage="dial"} 1
+clamav_scrape_errors_total{stage="other"} 0
+clamav_scrape_errors_total{stage="parse"} 0
+clamav_scrape_errors_total{stage="ping"} 0
+clamav_scrape_errors_total{stage="read"} 0
+clamav_scrape_errors_total{stage="send"} 0
+# HELP clamav_scrape_retries_total Number of ClamAV scrape retries.
+# TYPE clamav_scrape_retries_total counter
+clamav_scrape_retries_total 0
+# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 0
+`
+	metricNames := []string{
+		"clamav_scrape_errors_total",
+		"clamav_scrape_retries_total",
+		"clamav_up",
+	}
+	// The collect through WithContext runs collectContext with the deadline.
+	start := time.Now()
+	if err = testutil.CollectAndCompare(exporter.WithContext(ctx), strings.NewReader(want), metricNames...); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Collect() took %s; want the retry skipped", d)
+	}
+}
+
+func TestExporter_scrapeSocket_UnexpectedReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies map[string]string
+		up      string
+		parse   string
+		ping    string
+	}{
+		{
+			name:    "ping",
+			replies: map[string]string{"PING": "PANG"},
+			up:      "0",
+			parse:   "0",
+			ping:    "1",
+		},
+		{
+			name: "version",
+			replies: map[string]string{
+				"PING":    "PONG",
+				"VERSION": "VERSION",
+				"STATS":   "POOLS: 1\n\nSTATE: VALID PRIMARY\nEND",
+			},
+			up:    "1",
+			parse: "1",
+			ping:  "0",
+		},
+		{
+			name: "stats",
+			replies: map[string]string{
+				"PING":    "PONG",
+				"VERSION": "ClamAV 1.4.1/27482/Mon Dec  9 09:37:53 2024",
+				"STATS":   "STATS",
+			},
+			up:    "1",
+			parse: "1",
+			ping:  "0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter, err := New(serveClamd(t, test.replies), time.Second, 2, promslog.NewNopLogger())
+			if err != nil {
+				t.Fatalf("New() = _, %v; want nil", err)
+			}
+			want := `# HELP clamav_scrape_errors_total Number of ClamAV scrape errors by the failed stage.
+# TYPE clamav_scrape_errors_total counter
+clamav_scrape_errors_total{stage="dial"} 0
+clamav_scrape_errors_total{stage="other"} 0
+clamav_scrape_errors_total{stage="parse"} ` + test.parse + `
+clamav_scrape_errors_total{stage="ping"} ` + test.ping + `
+clamav_scrape_errors_total{stage="read"} 0
+clamav_scrape_errors_total{stage="send"} 0
+# HELP clamav_scrape_retries_total Number of ClamAV scrape retries.
+# TYPE clamav_scrape_retries_total counter
+clamav_scrape_retries_total 0
+# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up ` + test.up + `
+`
+			metricNames := []string{
+				"clamav_scrape_errors_total",
+				"clamav_scrape_retries_total",
+				"clamav_up",
+			}
+			if err = testutil.CollectAndCompare(exporter, strings.NewReader(want), metricNames...); err != nil {
+				t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+			}
+		})
+	}
+}
+
+func TestScrapeStage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: &clamd.Error{Op: clamd.OpDial, Err: syscall.ECONNREFUSED}, want: "dial"},
+		{err: &clamd.Error{Op: clamd.OpRead, Cmd: "STATS", Err: io.EOF}, want: "read"},
+		{err: &clamd.Error{Op: clamd.OpParse, Cmd: "PING", Err: clamd.ErrUnexpectedReply}, want: "ping"},
+		{err: &url.Error{Op: "parse", URL: "tcp://%", Err: errors.New("invalid URL escape")}, want: "other"},
+	}
+	for _, test := range tests {
+		if got := scrapeStage(test.err); got != test.want {
+			t.Errorf("scrapeStage(%v) = %q; want %q", test.err, got, test.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 6; attempt++ {
+		want := min(100*time.Millisecond<<attempt, time.Second)
+		if d := backoff(100*time.Millisecond, time.Second, attempt); d < want/2 || d > want {
+			t.Errorf("backoff(100ms, 1s, %d) = %s; want %s-%s", attempt, d, want/2, want)
+		}
+	}
+	if d := backoff(0, time.Second, 3); d != 0 {
+		t.Errorf("backoff(0, 1s, 3) = %s; want 0", d)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: &clamd.Error{Op: clamd.OpDial, Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, want: true},
+		{err: &clamd.Error{Op: clamd.OpRead, Cmd: "STATS", Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, want: true},
+		{err: &clamd.Error{Op: clamd.OpRead, Cmd: "STATS", Err: io.EOF}, want: true},
+		{err: &clamd.Error{Op: clamd.OpRead, Cmd: "STATS", Err: os.ErrDeadlineExceeded}, want: true},
+		{err: &clamd.Error{Op: clamd.OpParse, Cmd: "PING", Err: clamd.ErrUnexpectedReply}, want: false},
+		{err: &clamd.Error{Op: clamd.OpDial, Err: errors.New("tls: failed to verify certificate")}, want: false},
+	}
+	for _, test := range tests {
+		if got := transient(test.err); got != test.want {
+			t.Errorf("transient(%v) = %t; want %t", test.err, got, test.want)
+		}
+	}
+}
+
+func TestExporter_scrapeSocket_Canceled(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	// The fake daemon never replies.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	address := &url.URL{Scheme: "tcp", Host: l.Addr().String()}
+	exporter, err := New(address, time.Minute, 3, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	want := `# HELP clamav_scrape_errors_total Number of ClamAV scrape errors by the failed stage.
+# TYPE clamav_scrape_errors_total counter
+clamav_scrape_errors_total{stage="dial"} 0
+clamav_scrape_errors_total{stage="other"} 0
+clamav_scrape_errors_total{stage="parse"} 0
+clamav_scrape_errors_total{stage="ping"} 0
+clamav_scrape_errors_total{stage="read"} 0
+clamav_scrape_errors_total{stage="send"} 0
+# HELP clamav_scrape_retries_total Number of ClamAV scrape retries.
+# TYPE clamav_scrape_retries_total counter
+clamav_scrape_retries_total 0
+# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 0
+`
+	metricNames := []string{
+		"clamav_scrape_errors_total",
+		"clamav_scrape_retries_total",
+		"clamav_up",
+	}
+	start := time.Now()
+	if err = testutil.CollectAndCompare(exporter.WithContext(ctx), strings.NewReader(want), metricNames...); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Collect() took %s; want it canceled", d)
+	}
+	if r := exporter.lastResult(); r != nil {
+		t.Errorf("lastResult() = %+v; want nil", r)
+	}
+}
+
+func TestExporter_scrapeSocket_PersistentSession(t *testing.T) {
+	in, err := os.ReadFile("testdata/5-socket.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := strings.Split(strings.TrimSuffix(string(in), "\n"), "\n--\n")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go handleClamd(conn, map[string]string{
+				"PING":    resp[0],
+				"VERSION": resp[1],
+				"STATS":   resp[2],
+			})
+		}
+	}()
+	address := &url.URL{Scheme: "tcp", Host: l.Addr().String()}
+	exporter, err := New(address, time.Second, 0, promslog.NewNopLogger(), WithPersistentSession())
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	defer exporter.Close()
+	for i := 0; i < 2; i++ {
+		if r := exporter.scrapeShared(context.Background()); !r.ok {
+			t.Fatalf("scrape %d failed; want success", i)
+		}
+	}
+	if n := len(conns); n != 1 {
+		t.Fatalf("clamd accepted %d connections; want 1", n)
+	}
+	// Close the session like the daemon does after its IdleTimeout.
+	(<-conns).Close()
+	if r := exporter.scrapeShared(context.Background()); !r.ok {
+		t.Fatal("scrape after the session was closed failed; want success")
+	}
+	if n := len(conns); n != 1 {
+		t.Errorf("clamd accepted %d connections after the session was closed; want 1", n)
+	}
+	want := `# HELP clamav_scrape_errors_total Number of ClamAV scrape errors by the failed stage.
+# TYPE clamav_scrape_errors_total counter
+clamav_scrape_errors_total{stage="dial"} 0
+clamav_scrape_errors_total{stage="other"} 0
+clamav_scrape_errors_total{stage="parse"} 0
+clamav_scrape_errors_total{stage="ping"} 0
+clamav_scrape_errors_total{stage="read"} 0
+clamav_scrape_errors_total{stage="send"} 0
+# HELP clamav_session_reconnects_total Number of times the persistent ClamAV session failed and was reopened.
+# TYPE clamav_session_reconnects_total counter
+clamav_session_reconnects_total 1
+`
+	metricNames := []string{
+		"clamav_scrape_errors_total",
+		"clamav_session_reconnects_total",
+	}
+	if err = testutil.CollectAndCompare(exporter, strings.NewReader(want), metricNames...); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+	// A scrape in flight when the module is removed doesn't keep its session.
+	exporter.Close()
+	if r := exporter.scrapeShared(context.Background()); !r.ok {
+		t.Fatal("scrape after Close() failed; want success")
+	}
+	exporter.sessionMu.Lock()
+	defer exporter.sessionMu.Unlock()
+	if exporter.session != nil {
+		t.Error("scrape after Close() kept the session; want it closed")
+	}
+}
+
 func TestExporter_Collect_Clamd(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestExporter_Collect_Clamd during short test")
@@ -125,3 +520,74 @@ func collect(t *testing.T, c prometheus.Collector) []byte {
 	}
 	return buf.Bytes()
 }
+
+// serveClamd starts a fake ClamAV daemon replying to the commands with the given replies.
+// INSTREAM is replied only if the EICAR test file was streamed.
+func serveClamd(t *testing.T, replies map[string]string) *url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handleClamd(conn, replies)
+		}
+	}()
+	address, _ := url.Parse("tcp://" + l.Addr().String())
+	return address
+}
+
+func handleClamd(conn net.Conn, replies map[string]string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var (
+		session bool
+		id      int
+	)
+	for {
+		cmd, err := r.ReadString('\000')
+		if err != nil {
+			return
+		}
+		cmd = strings.TrimSuffix(strings.TrimPrefix(cmd, "z"), "\000")
+		reply := replies[cmd]
+		switch cmd {
+		case "IDSESSION":
+			session = true
+			continue
+		case "END":
+			return
+		case "INSTREAM":
+			var data []byte
+			for {
+				var n uint32
+				if err = binary.Read(r, binary.BigEndian, &n); err != nil {
+					return
+				}
+				if n == 0 {
+					break
+				}
+				chunk := make([]byte, n)
+				if _, err = io.ReadFull(r, chunk); err != nil {
+					return
+				}
+				data = append(data, chunk...)
+			}
+			if string(data) != eicar() {
+				reply = "stream: OK"
+			}
+		}
+		id++
+		if session {
+			reply = fmt.Sprintf("%d: %s", id, reply)
+		}
+		if _, err = conn.Write([]byte(reply + "\000")); err != nil || !session {
+			return
+		}
+	}
+}
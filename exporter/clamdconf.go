@@ -0,0 +1,106 @@
+package exporter
+
+import (
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sergeymakinen/clamav_exporter/v2/clamd"
+)
+
+// clamdConfigLimit is a numeric clamd.conf option exported as a gauge.
+type clamdConfigLimit struct {
+	option string
+	// def is the ClamAV 1.x default value used if the option isn't set.
+	def  int64
+	size bool
+	desc *prometheus.Desc
+}
+
+// ClamdConfigCollector collects the ClamAV daemon settings from a clamd.conf file
+// and exports them using the prometheus metrics package.
+type ClamdConfigCollector struct {
+	name   string
+	logger *slog.Logger
+
+	info   *prometheus.Desc
+	limits []clamdConfigLimit
+}
+
+// Describe describes all the metrics exported by the collector. It
+// implements prometheus.Collector.
+func (c *ClamdConfigCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	for _, limit := range c.limits {
+		ch <- limit.desc
+	}
+}
+
+// Collect reads the clamd.conf file, and
+// delivers the settings as Prometheus metrics. It implements prometheus.Collector.
+func (c *ClamdConfigCollector) Collect(ch chan<- prometheus.Metric) {
+	cfg, err := clamd.ReadConfig(c.name)
+	if err != nil {
+		c.logger.Error("Failed to read clamd.conf", "file", c.name, "err", err)
+		return
+	}
+	localSocket, _ := cfg.Value("LocalSocket")
+	tcpSocket, _ := cfg.Value("TCPSocket")
+	tcpAddr, _ := cfg.Value("TCPAddr")
+	dbDir, _ := cfg.Value("DatabaseDirectory")
+	ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, 1, c.name, localSocket, tcpSocket, tcpAddr, dbDir)
+	for _, limit := range c.limits {
+		var (
+			n   int64
+			err error
+		)
+		if limit.size {
+			n, err = cfg.Size(limit.option, limit.def)
+		} else {
+			n, err = cfg.Int(limit.option, limit.def)
+		}
+		if err != nil {
+			c.logger.Error("Failed to parse clamd.conf option", "file", c.name, "err", err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(limit.desc, prometheus.GaugeValue, float64(n))
+	}
+}
+
+// NewClamdConfigCollector returns an initialized clamd.conf collector.
+func NewClamdConfigCollector(name string, logger *slog.Logger) *ClamdConfigCollector {
+	limit := func(option string, def int64, size bool, metric, help string) clamdConfigLimit {
+		return clamdConfigLimit{
+			option: option,
+			def:    def,
+			size:   size,
+			desc: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "config", metric),
+				help,
+				nil,
+				nil,
+			),
+		}
+	}
+	return &ClamdConfigCollector{
+		name:   name,
+		logger: logger,
+
+		info: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "config", "info"),
+			"Information about the ClamAV daemon configuration.",
+			[]string{"file", "local_socket", "tcp_socket", "tcp_addr", "database_directory"},
+			nil,
+		),
+		limits: []clamdConfigLimit{
+			limit("MaxThreads", 10, false, "max_threads", "Maximum number of threads running at the same time."),
+			limit("MaxQueue", 100, false, "max_queue", "Maximum number of queued items."),
+			limit("MaxConnectionQueueLength", 200, false, "max_connection_queue_length", "Maximum length the queue of pending connections may grow to."),
+			limit("StreamMaxLength", 100*1024*1024, true, "stream_max_length_bytes", "Maximum size of the data streamed to the daemon in bytes."),
+			limit("MaxScanSize", 400*1024*1024, true, "max_scan_size_bytes", "Maximum amount of data scanned for each input file in bytes."),
+			limit("MaxFileSize", 100*1024*1024, true, "max_file_size_bytes", "Maximum size of a scanned file in bytes."),
+			limit("MaxRecursion", 17, false, "max_recursion", "Maximum nesting level of scanned archives."),
+			limit("SelfCheck", 600, false, "self_check_seconds", "Interval of the database checks in seconds."),
+			limit("ReadTimeout", 120, false, "read_timeout_seconds", "Timeout of reading data from the clients in seconds."),
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestClamdConfigCollector_Collect(t *testing.T) {
+	want := `# HELP clamav_config_info Information about the ClamAV daemon configuration.
+# TYPE clamav_config_info gauge
+clamav_config_info{database_directory="/var/lib/clamav",file="testdata/clamd.conf",local_socket="/run/clamav/clamd.ctl",tcp_addr="::1",tcp_socket="3310"} 1
+# HELP clamav_config_max_connection_queue_length Maximum length the queue of pending connections may grow to.
+# TYPE clamav_config_max_connection_queue_length gauge
+clamav_config_max_connection_queue_length 30
+# HELP clamav_config_max_file_size_bytes Maximum size of a scanned file in bytes.
+# TYPE clamav_config_max_file_size_bytes gauge
+clamav_config_max_file_size_bytes 2.62144e+07
+# HELP clamav_config_max_queue Maximum number of queued items.
+# TYPE clamav_config_max_queue gauge
+clamav_config_max_queue 100
+# HELP clamav_config_max_recursion Maximum nesting level of scanned archives.
+# TYPE clamav_config_max_recursion gauge
+clamav_config_max_recursion 16
+# HELP clamav_config_max_scan_size_bytes Maximum amount of data scanned for each input file in bytes.
+# TYPE clamav_config_max_scan_size_bytes gauge
+clamav_config_max_scan_size_bytes 1.073741824e+09
+# HELP clamav_config_max_threads Maximum number of threads running at the same time.
+# TYPE clamav_config_max_threads gauge
+clamav_config_max_threads 20
+# HELP clamav_config_read_timeout_seconds Timeout of reading data from the clients in seconds.
+# TYPE clamav_config_read_timeout_seconds gauge
+clamav_config_read_timeout_seconds 180
+# HELP clamav_config_self_check_seconds Interval of the database checks in seconds.
+# TYPE clamav_config_self_check_seconds gauge
+clamav_config_self_check_seconds 3600
+# HELP clamav_config_stream_max_length_bytes Maximum size of the data streamed to the daemon in bytes.
+# TYPE clamav_config_stream_max_length_bytes gauge
+clamav_config_stream_max_length_bytes 2.62144e+07
+`
+	c := NewClamdConfigCollector("testdata/clamd.conf", promslog.NewNopLogger())
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+}
+
+func TestClamdConfigCollector_Collect_Example(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "clamd.conf")
+	if err := os.WriteFile(name, []byte("Example\nLocalSocket /run/clamav/clamd.ctl\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClamdConfigCollector(name, promslog.NewNopLogger())
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("testutil.CollectAndCount() = %d; want 0", n)
+	}
+}
@@ -0,0 +1,180 @@
+package exporter
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// otherSignature is the signature label value of the detections
+// over the signature limit.
+const otherSignature = "__other__"
+
+var (
+	reClamdFound       = regexp.MustCompile(`: (\S+?)(?:\([^)]*\))? FOUND$`)
+	reClamdScanError   = regexp.MustCompile(`: (.+) ERROR$`)
+	reClamdSelfCheck   = regexp.MustCompile(`^SelfCheck: (?:Database status (OK)|Database (modification) detected)`)
+	reClamdReload      = regexp.MustCompile(`(?i)database (?:correctly reloaded|reload completed)`)
+	reClamdReloadError = regexp.MustCompile(`(?i)(?:reload db|database reload) failed`)
+)
+
+// clamdScanErrors maps the scan error types to the patterns of the clamd log messages.
+// The first matching pattern wins.
+var clamdScanErrors = []struct {
+	Type    string
+	Pattern *regexp.Regexp
+}{
+	{Type: "open", Pattern: regexp.MustCompile(`(?i)can't open|access denied|permission denied|no such file`)},
+	{Type: "size_limit", Pattern: regexp.MustCompile(`(?i)size limit|exceeds? limits|too large`)},
+}
+
+// ClamdLogCollector collects detection and event stats from the clamd log file
+// and exports them using the prometheus metrics package.
+type ClamdLogCollector struct {
+	tailer        *tailer
+	maxSignatures int
+	logger        *slog.Logger
+	mu            sync.Mutex
+
+	detections map[string]float64
+	scanErrors map[string]float64
+	selfChecks map[string]float64
+	reloads    map[string]float64
+	lastReload float64
+
+	detectionsDesc *prometheus.Desc
+	scanErrorsDesc *prometheus.Desc
+	selfChecksDesc *prometheus.Desc
+	reloadsDesc    *prometheus.Desc
+	lastReloadDesc *prometheus.Desc
+}
+
+// Describe describes all the metrics exported by the collector. It
+// implements prometheus.Collector.
+func (c *ClamdLogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.detectionsDesc
+	ch <- c.scanErrorsDesc
+	ch <- c.selfChecksDesc
+	ch <- c.reloadsDesc
+	ch <- c.lastReloadDesc
+}
+
+// Collect reads the lines appended to the log file, and
+// delivers the stats as Prometheus metrics. It implements prometheus.Collector.
+func (c *ClamdLogCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.tailer.lines(c.parse); err != nil {
+		c.logger.Error("Failed to read clamd log", "file", c.tailer.name, "err", err)
+	}
+	for signature, n := range c.detections {
+		ch <- prometheus.MustNewConstMetric(c.detectionsDesc, prometheus.CounterValue, n, signature)
+	}
+	for _, scanError := range clamdScanErrors {
+		ch <- prometheus.MustNewConstMetric(c.scanErrorsDesc, prometheus.CounterValue, c.scanErrors[scanError.Type], scanError.Type)
+	}
+	for _, typ := range []string{"heuristics", "other"} {
+		ch <- prometheus.MustNewConstMetric(c.scanErrorsDesc, prometheus.CounterValue, c.scanErrors[typ], typ)
+	}
+	for _, result := range []string{"ok", "modified"} {
+		ch <- prometheus.MustNewConstMetric(c.selfChecksDesc, prometheus.CounterValue, c.selfChecks[result], result)
+	}
+	for _, result := range []string{"success", "failure"} {
+		ch <- prometheus.MustNewConstMetric(c.reloadsDesc, prometheus.CounterValue, c.reloads[result], result)
+	}
+	if c.lastReload > 0 {
+		ch <- prometheus.MustNewConstMetric(c.lastReloadDesc, prometheus.GaugeValue, c.lastReload)
+	}
+}
+
+func (c *ClamdLogCollector) parse(line string) {
+	t, msg := parseLogLine(line)
+	if matches := reClamdFound.FindStringSubmatch(msg); matches != nil {
+		signature := matches[1]
+		if strings.HasPrefix(signature, "Heuristics.Limits.Exceeded") {
+			c.scanErrors["heuristics"]++
+			return
+		}
+		if _, ok := c.detections[signature]; !ok && len(c.detections) >= c.maxSignatures {
+			signature = otherSignature
+		}
+		c.detections[signature]++
+		return
+	}
+	if matches := reClamdScanError.FindStringSubmatch(msg); matches != nil {
+		typ := "other"
+		for _, scanError := range clamdScanErrors {
+			if scanError.Pattern.MatchString(matches[1]) {
+				typ = scanError.Type
+				break
+			}
+		}
+		c.scanErrors[typ]++
+		return
+	}
+	if matches := reClamdSelfCheck.FindStringSubmatch(msg); matches != nil {
+		if matches[1] != "" {
+			c.selfChecks["ok"]++
+		} else {
+			c.selfChecks["modified"]++
+		}
+		return
+	}
+	if reClamdReload.MatchString(msg) {
+		c.reloads["success"]++
+		c.lastReload = float64(t.Unix())
+		return
+	}
+	if reClamdReloadError.MatchString(msg) {
+		c.reloads["failure"]++
+	}
+}
+
+// NewClamdLogCollector returns an initialized clamd log collector.
+// Detections are exported for up to maxSignatures distinct signatures,
+// the rest are exported using the "__other__" signature.
+func NewClamdLogCollector(logFile string, maxSignatures int, logger *slog.Logger) *ClamdLogCollector {
+	return &ClamdLogCollector{
+		tailer:        &tailer{name: logFile},
+		maxSignatures: maxSignatures,
+		logger:        logger,
+		detections:    make(map[string]float64),
+		scanErrors:    make(map[string]float64),
+		selfChecks:    make(map[string]float64),
+		reloads:       make(map[string]float64),
+
+		detectionsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "detections_total"),
+			"Number of detections logged by the ClamAV daemon by signature.",
+			[]string{"signature"},
+			nil,
+		),
+		scanErrorsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "scan_errors_total"),
+			"Number of scan errors logged by the ClamAV daemon by type.",
+			[]string{"type"},
+			nil,
+		),
+		selfChecksDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "self_checks_total"),
+			"Number of database self-checks logged by the ClamAV daemon by result.",
+			[]string{"result"},
+			nil,
+		),
+		reloadsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "db", "reloads_total"),
+			"Number of database reloads logged by the ClamAV daemon by result.",
+			[]string{"result"},
+			nil,
+		),
+		lastReloadDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "db", "last_reload_timestamp_seconds"),
+			"Unix timestamp of the last successful database reload logged by the ClamAV daemon.",
+			nil,
+			nil,
+		),
+	}
+}
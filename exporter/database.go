@@ -0,0 +1,176 @@
+package exporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cvdHeaderSize is the size of the ClamAV Virus Database file header.
+const cvdHeaderSize = 512
+
+// databases lists the official ClamAV Virus Databases.
+var databases = []string{"main", "daily", "bytecode"}
+
+// DatabaseCollector collects ClamAV Virus Database file stats from a directory
+// and exports them using the prometheus metrics package.
+type DatabaseCollector struct {
+	dir    string
+	logger *slog.Logger
+
+	version            *prometheus.Desc
+	signatures         *prometheus.Desc
+	functionalityLevel *prometheus.Desc
+	buildTime          *prometheus.Desc
+	size               *prometheus.Desc
+}
+
+// Describe describes all the metrics exported by the collector. It
+// implements prometheus.Collector.
+func (c *DatabaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.version
+	ch <- c.signatures
+	ch <- c.functionalityLevel
+	ch <- c.buildTime
+	ch <- c.size
+}
+
+// Collect reads the database file headers, and
+// delivers them as Prometheus metrics. It implements prometheus.Collector.
+func (c *DatabaseCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, name := range databases {
+		var (
+			db   *database
+			file string
+		)
+		for _, ext := range []string{".cvd", ".cld"} {
+			f := filepath.Join(c.dir, name+ext)
+			d, err := readDatabase(f)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					c.logger.Error("Failed to read database", "file", f, "err", err)
+				}
+				continue
+			}
+			// Both files exist while freshclam is updating the database.
+			if db == nil || d.Version > db.Version {
+				db, file = d, filepath.Base(f)
+			}
+		}
+		if db == nil {
+			continue
+		}
+		labelValues := []string{name, file}
+		ch <- prometheus.MustNewConstMetric(c.version, prometheus.GaugeValue, float64(db.Version), labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.signatures, prometheus.GaugeValue, float64(db.Signatures), labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.functionalityLevel, prometheus.GaugeValue, float64(db.FunctionalityLevel), labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.buildTime, prometheus.GaugeValue, float64(db.BuildTime.Unix()), labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(db.Size), labelValues...)
+	}
+}
+
+type database struct {
+	Version            uint32
+	Signatures         uint64
+	FunctionalityLevel uint32
+	BuildTime          time.Time
+	Size               int64
+}
+
+// readDatabase reads the CVD header of a database file. The header is a
+// colon-separated list of fields padded with spaces:
+//
+//	ClamAV-VDB:build time:version:signatures:functionality level:MD5:digital signature:builder:build timestamp
+func readDatabase(name string) (*database, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, cvdHeaderSize)
+	if _, err = io.ReadFull(f, b); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	fields := strings.Split(string(bytes.TrimRight(b, " \000")), ":")
+	if len(fields) < 8 || fields[0] != "ClamAV-VDB" {
+		return nil, errors.New("invalid header")
+	}
+	db := &database{Size: fi.Size()}
+	n, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version: %w", err)
+	}
+	db.Version = uint32(n)
+	if db.Signatures, err = strconv.ParseUint(fields[3], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid signature count: %w", err)
+	}
+	if n, err = strconv.ParseUint(fields[4], 10, 32); err != nil {
+		return nil, fmt.Errorf("invalid functionality level: %w", err)
+	}
+	db.FunctionalityLevel = uint32(n)
+	if len(fields) > 8 {
+		if sec, err := strconv.ParseInt(fields[8], 10, 64); err == nil {
+			db.BuildTime = time.Unix(sec, 0)
+			return db, nil
+		}
+	}
+	// Old headers have no build timestamp field.
+	if db.BuildTime, err = time.Parse("02 Jan 2006 15-04 -0700", fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid build time: %w", err)
+	}
+	return db, nil
+}
+
+// NewDatabaseCollector returns an initialized database collector
+// for the database directory.
+func NewDatabaseCollector(dir string, logger *slog.Logger) *DatabaseCollector {
+	labels := []string{"database", "file"}
+	return &DatabaseCollector{
+		dir:    dir,
+		logger: logger,
+
+		version: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "db", "file_version"),
+			"Version of the ClamAV Virus Database file.",
+			labels,
+			nil,
+		),
+		signatures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "db", "file_signatures"),
+			"Number of signatures in the ClamAV Virus Database file.",
+			labels,
+			nil,
+		),
+		functionalityLevel: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "db", "file_functionality_level"),
+			"Minimum functionality level required by the ClamAV Virus Database file.",
+			labels,
+			nil,
+		),
+		buildTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "db", "file_build_timestamp_seconds"),
+			"Unix timestamp of the ClamAV Virus Database file build time.",
+			labels,
+			nil,
+		),
+		size: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "db", "file_size_bytes"),
+			"Size of the ClamAV Virus Database file in bytes.",
+			labels,
+			nil,
+		),
+	}
+}
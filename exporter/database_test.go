@@ -0,0 +1,27 @@
+package exporter
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestDatabaseCollector_Collect(t *testing.T) {
+	collector := NewDatabaseCollector("testdata/database", promslog.NewNopLogger())
+	b, err := os.ReadFile("testdata/database-metrics.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(collector, bytes.NewReader(b)); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+}
+
+func TestReadDatabase_Invalid(t *testing.T) {
+	if _, err := readDatabase("testdata/1-socket.txt"); err == nil {
+		t.Error("readDatabase() = _, nil; want error")
+	}
+}
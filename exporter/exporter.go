@@ -2,32 +2,43 @@
 package exporter
 
 import (
-	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net"
 	"net/url"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sergeymakinen/clamav_exporter/v2/clamd"
 )
 
 const namespace = "clamav"
 
-var (
-	reVersion    = regexp.MustCompile(`ClamAV (.+)/(\d+)/(.+)`)
-	rePool       = regexp.MustCompile(`STATE: ([^\n]+)\nTHREADS: ([^\n]+)\nQUEUE: ([^\n]+)\n`)
-	reThreadStat = regexp.MustCompile(`([a-z\-]+) (\d+)`)
-	reQueue      = regexp.MustCompile(`(\d+) items min_wait: (\d+\.\d+) max_wait: (\d+\.\d+) avg_wait: (\d+\.\d+)`)
-	reMemStats   = regexp.MustCompile(`MEMSTATS: (.+)`)
-	reMemStat    = regexp.MustCompile(`([a-z_]+) ([\d.]+)M`)
-)
+// eicarReversed is the EICAR anti-virus test file reversed, so the exporter
+// binary has no copy of it to be detected by anti-virus scanners.
+const eicarReversed = `*H+H$!ELIF-TSET-SURIVITNA-DRADNATS-RACIE$}7)CC7)^P(45XZP\4[PA@%P!O5X`
+
+// eicar returns the EICAR anti-virus test file. It's built at runtime
+// as the compiler would fold a constant expression into the binary.
+func eicar() string {
+	b := []byte(eicarReversed)
+	slices.Reverse(b)
+	return string(b)
+}
+
+// Stages of a scrape that can fail.
+var scrapeStages = []string{"dial", "send", "read", "parse", "ping", "other"}
 
 var states = map[string]float64{
 	"INVALID": 0,
@@ -35,38 +46,95 @@ var states = map[string]float64{
 	"EXIT":    2,
 }
 
-var tz = time.Local
+var (
+	tz  = time.Local
+	now = time.Now
+)
 
 // Exporter collects ClamAV daemon stats via a TCP socket and exports them
 // using the prometheus metrics package.
 type Exporter struct {
-	scrape  func(e *Exporter) (m metrics, ok bool)
+	scrape  func(e *Exporter, ctx context.Context) (m metrics, ok bool)
 	address *url.URL
 	timeout time.Duration
 	retries int
 	logger  *slog.Logger
 	mu      sync.Mutex
 
-	up                     *prometheus.Desc
-	version                *prometheus.Desc
-	dbVersion              *prometheus.Desc
-	dbTime                 *prometheus.Desc
-	poolState              *prometheus.Desc
-	poolLiveThreads        *prometheus.Desc
-	poolIdleThreads        *prometheus.Desc
-	poolMaxThreads         *prometheus.Desc
-	poolIdleTimeoutThreads *prometheus.Desc
-	poolQueueLength        *prometheus.Desc
-	poolQueueMinWait       *prometheus.Desc
-	poolQueueMaxWait       *prometheus.Desc
-	poolQueueAvgWait       *prometheus.Desc
-	heapMemory             *prometheus.Desc
-	mmapMemory             *prometheus.Desc
-	usedMemory             *prometheus.Desc
-	freeMemory             *prometheus.Desc
-	releasableMemory       *prometheus.Desc
-	poolsUsedMemory        *prometheus.Desc
-	poolsTotalMemory       *prometheus.Desc
+	tlsConfig         *tls.Config
+	collectors        map[string]bool
+	maxResultAge      time.Duration
+	dbMaxAge          time.Duration
+	persistentSession bool
+	retryBackoff      time.Duration
+	maxRetryBackoff   time.Duration
+	publishedRecord   string
+	resolver          string
+
+	// sessionMu serializes the queries, so the persistent session isn't shared.
+	sessionMu sync.Mutex
+	session   *session
+	// closed is set by Close, so the sessions of the scrapes still in flight aren't kept.
+	closed bool
+
+	flightMu sync.Mutex
+	flight   *flight
+	polling  atomic.Bool
+	resultMu sync.Mutex
+	result   *result
+	// polled is the last polled result.
+	polled *result
+	// publishedMu serializes the lookups of the published versions apart
+	// from the queries, so an unrelated DNS lookup doesn't delay them.
+	publishedMu sync.Mutex
+	published   publishedCache
+
+	up                       *prometheus.Desc
+	version                  *prometheus.Desc
+	dbVersion                *prometheus.Desc
+	dbTime                   *prometheus.Desc
+	dbAge                    *prometheus.Desc
+	dbStale                  *prometheus.Desc
+	poolState                *prometheus.Desc
+	poolLiveThreads          *prometheus.Desc
+	poolIdleThreads          *prometheus.Desc
+	poolMaxThreads           *prometheus.Desc
+	poolIdleTimeoutThreads   *prometheus.Desc
+	poolQueueLength          *prometheus.Desc
+	poolQueueMinWait         *prometheus.Desc
+	poolQueueMaxWait         *prometheus.Desc
+	poolQueueAvgWait         *prometheus.Desc
+	poolActiveCommands       *prometheus.Desc
+	poolActiveCommandMaxTime *prometheus.Desc
+	heapMemory               *prometheus.Desc
+	mmapMemory               *prometheus.Desc
+	usedMemory               *prometheus.Desc
+	freeMemory               *prometheus.Desc
+	releasableMemory         *prometheus.Desc
+	poolsUsedMemory          *prometheus.Desc
+	poolsTotalMemory         *prometheus.Desc
+	scanProbeSuccess         *prometheus.Desc
+	scanProbeDuration        *prometheus.Desc
+	publishedVersion         *prometheus.Desc
+	publishedDBVersion       *prometheus.Desc
+	publishedDBTime          *prometheus.Desc
+	dbVersionsBehind         *prometheus.Desc
+	lastScrapeTime           *prometheus.Desc
+	lastScrapeAge            *prometheus.Desc
+	scrapeDuration           *prometheus.Desc
+	scrapeResponseSize       *prometheus.Desc
+	scrapeErrors             *prometheus.CounterVec
+	scrapeRetries            prometheus.Counter
+	statsWarnings            prometheus.Counter
+	sessionReconnects        prometheus.Counter
+	publishedLookupErrors    prometheus.Counter
+}
+
+// result is the result of a scrape.
+type result struct {
+	m    metrics
+	ok   bool
+	time time.Time
 }
 
 // Describe describes all the metrics exported by the ClamAV exporter. It
@@ -76,6 +144,8 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.version
 	ch <- e.dbVersion
 	ch <- e.dbTime
+	ch <- e.dbAge
+	ch <- e.dbStale
 	ch <- e.poolState
 	ch <- e.poolLiveThreads
 	ch <- e.poolIdleThreads
@@ -85,6 +155,8 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.poolQueueMinWait
 	ch <- e.poolQueueMaxWait
 	ch <- e.poolQueueAvgWait
+	ch <- e.poolActiveCommands
+	ch <- e.poolActiveCommandMaxTime
 	ch <- e.heapMemory
 	ch <- e.mmapMemory
 	ch <- e.usedMemory
@@ -92,185 +164,558 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.releasableMemory
 	ch <- e.poolsUsedMemory
 	ch <- e.poolsTotalMemory
+	ch <- e.scanProbeSuccess
+	ch <- e.scanProbeDuration
+	ch <- e.publishedVersion
+	ch <- e.publishedDBVersion
+	ch <- e.publishedDBTime
+	ch <- e.dbVersionsBehind
+	ch <- e.lastScrapeTime
+	ch <- e.lastScrapeAge
+	ch <- e.scrapeDuration
+	ch <- e.scrapeResponseSize
+	e.scrapeErrors.Describe(ch)
+	ch <- e.scrapeRetries.Desc()
+	ch <- e.statsWarnings.Desc()
+	ch <- e.sessionReconnects.Desc()
+	ch <- e.publishedLookupErrors.Desc()
 }
 
 // Collect fetches the statistics from ClamAV, and
 // delivers them as Prometheus metrics. It implements prometheus.Collector.
+// Concurrent calls share a single scrape. If the exporter is polling,
+// the last polled statistics are delivered instead.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	m, ok := e.scrape(e)
-	if !ok {
+	e.collectContext(context.Background(), ch)
+}
+
+// WithContext returns a collector of the exporter metrics that aborts
+// the scrape of ClamAV when ctx is done, e.g. when the HTTP request
+// of the scrape is canceled or times out.
+func (e *Exporter) WithContext(ctx context.Context) prometheus.Collector {
+	return &contextCollector{e: e, ctx: ctx}
+}
+
+type contextCollector struct {
+	e   *Exporter
+	ctx context.Context
+}
+
+func (c *contextCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.e.Describe(ch)
+}
+
+func (c *contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.e.collectContext(c.ctx, ch)
+}
+
+func (e *Exporter) collectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	var r *result
+	defer func() {
+		e.scrapeErrors.Collect(ch)
+		ch <- e.scrapeRetries
+		ch <- e.statsWarnings
+		e.mu.Lock()
+		persistent := e.persistentSession
+		e.mu.Unlock()
+		if persistent {
+			ch <- e.sessionReconnects
+		}
+		if e.enabled(CollectorPublished) {
+			var local *db
+			if r != nil && r.ok {
+				local = r.m.DB
+			}
+			if p := e.publishedVersions(ctx); p != nil {
+				e.collectPublished(p, local, ch)
+			}
+			ch <- e.publishedLookupErrors
+		}
+	}()
+	if r = e.currentResult(ctx); r == nil {
+		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
+		return
+	}
+	if e.polling.Load() {
+		ch <- prometheus.MustNewConstMetric(e.lastScrapeTime, prometheus.GaugeValue, float64(r.time.UnixNano())/1e9)
+		ch <- prometheus.MustNewConstMetric(e.lastScrapeAge, prometheus.GaugeValue, time.Since(r.time).Seconds())
+	}
+	if r.m.Scrape != nil {
+		ch <- prometheus.MustNewConstMetric(e.scrapeDuration, prometheus.GaugeValue, r.m.Scrape.Duration)
+		ch <- prometheus.MustNewConstMetric(e.scrapeResponseSize, prometheus.GaugeValue, float64(r.m.Scrape.ResponseSize))
+	}
+	if !r.ok {
 		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
 		return
 	}
 
-	e.collect(m, ch)
+	e.collect(r.m, ch)
 }
 
-func (e *Exporter) scrapeSocket() (m metrics, ok bool) {
+// currentResult returns the last polled result if the exporter is polling
+// (nil if there's none yet), otherwise the shared result.
+func (e *Exporter) currentResult(ctx context.Context) *result {
+	if e.polling.Load() {
+		e.resultMu.Lock()
+		defer e.resultMu.Unlock()
+		return e.polled
+	}
+	return e.sharedResult(ctx)
+}
+
+// Health checks the ClamAV daemon is up and, if the database max age is set,
+// its database isn't older than that. It scrapes ClamAV like Collect does.
+func (e *Exporter) Health(ctx context.Context) error {
+	r := e.currentResult(ctx)
+	if r == nil {
+		return errors.New("ClamAV daemon isn't scraped yet")
+	}
+	if !r.ok {
+		return errors.New("ClamAV daemon is down")
+	}
 	e.mu.Lock()
-	defer e.mu.Unlock()
-	var resp [][]byte
-	scrape := func(retries int) bool {
-		network, addr := e.address.Scheme, e.address.Host
-		if network == "unix" {
-			addr = e.address.Path
-		}
-		conn, err := net.DialTimeout(network, addr, e.timeout)
-		if err != nil {
-			e.logger.Error("Failed to connect to clamd", "err", err, "retries", retries)
-			return false
-		}
-		defer conn.Close()
-		// Following the recommendations:
-		// 	Clamd requires clients to read all the replies it sent, before sending more commands to prevent send()
-		// 	deadlocks. The recommended way to implement a client that uses IDSESSION is with non-blocking sockets,
-		// 	and a select()/poll() loop: whenever send would block, sleep in select/poll until either you can write
-		// 	more data, or read more replies.
-		var mu sync.Mutex
-		send := func(cmd string) bool {
-			mu.Lock()
-			defer mu.Unlock()
-			conn.SetWriteDeadline(time.Now().Add(e.timeout))
-			if _, err := conn.Write([]byte("z" + cmd + "\000")); err != nil {
-				e.logger.Error("Failed to send command", "cmd", cmd, "err", err, "retries", retries)
-				return false
-			}
-			return true
-		}
-		resp = make([][]byte, 3)
-		var wg sync.WaitGroup
-		wg.Add(1)
+	maxAge := e.dbMaxAge
+	e.mu.Unlock()
+	if maxAge == 0 {
+		return nil
+	}
+	if r.m.DB == nil {
+		return errors.New("ClamAV Virus Database time is unknown")
+	}
+	t, err := r.m.DB.time()
+	if err != nil {
+		return fmt.Errorf("failed to parse database time %q: %w", r.m.DB.Time, err)
+	}
+	if age := now().Sub(t); age > maxAge {
+		return fmt.Errorf("ClamAV Virus Database is %s old, more than %s", age.Truncate(time.Second), maxAge)
+	}
+	return nil
+}
+
+// sharedResult returns the last result if it's not older than the max result age,
+// otherwise it scrapes ClamAV.
+func (e *Exporter) sharedResult(ctx context.Context) *result {
+	e.mu.Lock()
+	maxAge := e.maxResultAge
+	e.mu.Unlock()
+	if r := e.lastResult(); r != nil && maxAge > 0 && time.Since(r.time) <= maxAge {
+		return r
+	}
+	return e.scrapeShared(ctx)
+}
+
+// flight is a scrape in flight shared by the callers waiting for it.
+type flight struct {
+	done    chan struct{}
+	r       *result
+	waiters int
+	cancel  context.CancelFunc
+
+	deadlineMu sync.Mutex
+	// deadline is the latest deadline of the callers. It's zero if any of them has none.
+	deadline  time.Time
+	unbounded bool
+}
+
+// join adds the deadline of the caller ctx to the scrape.
+func (f *flight) join(ctx context.Context) {
+	f.deadlineMu.Lock()
+	defer f.deadlineMu.Unlock()
+	if d, ok := ctx.Deadline(); !ok {
+		f.unbounded = true
+		f.deadline = time.Time{}
+	} else if !f.unbounded && d.After(f.deadline) {
+		f.deadline = d
+	}
+}
+
+// flightContext is the context of a shared scrape. Its deadline is the latest
+// deadline of the callers, so the scrape stops retrying once none of them
+// would get its result in time.
+type flightContext struct {
+	context.Context
+	f *flight
+}
+
+func (c flightContext) Deadline() (time.Time, bool) {
+	c.f.deadlineMu.Lock()
+	defer c.f.deadlineMu.Unlock()
+	return c.f.deadline, !c.f.deadline.IsZero()
+}
+
+// scrapeShared scrapes ClamAV and saves the result. Callers arriving
+// while a scrape is in flight wait for it and get its result.
+// The scrape isn't bound to ctx of the caller that started it: a caller stops
+// waiting when its ctx is done, and the scrape is aborted only when no caller
+// waits for it anymore. Such a result isn't saved. The scrape deadline is
+// the latest deadline of the callers.
+func (e *Exporter) scrapeShared(ctx context.Context) *result {
+	e.flightMu.Lock()
+	f := e.flight
+	if f == nil {
+		cancelCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		f.join(ctx)
+		e.flight = f
+		scrapeCtx := flightContext{Context: cancelCtx, f: f}
 		go func() {
-			defer wg.Done()
-			for {
-				conn.SetReadDeadline(time.Now().Add(e.timeout))
-				if _, err = conn.Read(nil); err != nil {
-					e.logger.Error("Failed to read response", "err", err, "retries", retries)
-					break
-				}
-				mu.Lock()
-				var b []byte
-				b, err = io.ReadAll(conn)
-				mu.Unlock()
-				if err != nil {
-					e.logger.Error("Failed to read response", "err", err, "retries", retries)
-					break
-				}
-				if err = parseResponse(b, resp); err != nil {
-					e.logger.Error("Failed to parse response", "err", err, "retries", retries)
-					break
-				}
-				if len(b) == 0 {
-					break
-				}
+			defer cancel()
+			m, ok := e.scrape(e, scrapeCtx)
+			r := &result{m: m, ok: ok, time: time.Now()}
+			if !done(scrapeCtx) {
+				e.resultMu.Lock()
+				e.result = r
+				e.resultMu.Unlock()
+			}
+			e.flightMu.Lock()
+			defer e.flightMu.Unlock()
+			f.r = r
+			close(f.done)
+			if e.flight == f {
+				e.flight = nil
 			}
 		}()
-		send("IDSESSION")
-		send("PING")
-		send("VERSION")
-		send("STATS")
-		send("END")
-		wg.Wait()
-		if err != nil {
-			return false
+	} else {
+		f.join(ctx)
+	}
+	f.waiters++
+	e.flightMu.Unlock()
+	select {
+	case <-f.done:
+		return f.r
+	case <-ctx.Done():
+		e.flightMu.Lock()
+		defer e.flightMu.Unlock()
+		if f.waiters--; f.waiters == 0 {
+			f.cancel()
+			// The next callers start a new scrape instead of joining the aborted one.
+			if e.flight == f {
+				e.flight = nil
+			}
 		}
-		return true
+		return &result{time: time.Now()}
 	}
-	for retries := e.retries; retries >= 0; retries-- {
-		if scrape(retries) {
-			return e.scrapeClamd(resp)
+}
+
+func (e *Exporter) lastResult() *result {
+	e.resultMu.Lock()
+	defer e.resultMu.Unlock()
+	return e.result
+}
+
+func (e *Exporter) scrapeSocket(ctx context.Context) (m metrics, ok bool) {
+	// Copy the settings, so Configure doesn't wait for the network round trip.
+	e.mu.Lock()
+	var (
+		settings = sessionSettings{
+			address:   e.address.String(),
+			timeout:   e.timeout,
+			tlsConfig: e.tlsConfig,
+		}
+		retries    = e.retries
+		minDelay   = e.retryBackoff
+		maxDelay   = e.maxRetryBackoff
+		persistent = e.persistentSession
+		scan       = e.enabledLocked(CollectorScan)
+	)
+	e.mu.Unlock()
+	e.sessionMu.Lock()
+	defer e.sessionMu.Unlock()
+	start := time.Now()
+	var n int64
+	for attempt := 0; ; attempt++ {
+		var (
+			r   *replies
+			err error
+		)
+		r, n, err = e.querySession(ctx, settings, persistent, scan)
+		if err == nil {
+			m, ok = e.parseReplies(r), true
+			break
+		}
+		if done(ctx) {
+			// The remaining retries are skipped as nobody waits for the scrape anymore.
+			e.logger.Debug("Scrape of clamd canceled", "err", err)
+			break
+		}
+		e.scrapeErrors.WithLabelValues(scrapeStage(err)).Inc()
+		if attempt == retries || !transient(err) {
+			e.logger.Error("Failed to scrape clamd", "err", err)
+			break
 		}
+		delay := backoff(minDelay, maxDelay, attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			e.logger.Error("Failed to scrape clamd, no time left to retry", "err", err)
+			break
+		}
+		e.logger.Error("Failed to scrape clamd, retrying", "err", err, "retries", retries-attempt, "backoff", delay)
+		e.scrapeRetries.Inc()
+		if !sleep(ctx, delay) {
+			break
+		}
+	}
+	m.Scrape = &scrapeStats{
+		Duration:     time.Since(start).Seconds(),
+		ResponseSize: int(n),
 	}
 	return
 }
 
-func (e *Exporter) scrapeClamd(resp [][]byte) (m metrics, ok bool) {
-	if !bytes.Equal(resp[0], []byte("PONG")) {
-		e.logger.Error("Unexpected PING response", "resp", resp[0])
-		return
+// backoff returns the delay before the retry after the given number of failed attempts.
+// It doubles from min up to max with a random jitter of up to a half of it.
+func backoff(min, max time.Duration, attempt int) time.Duration {
+	d := min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d - rand.N(d/2+1)
+}
+
+// sleep waits for d or until ctx is done. It reports whether it waited for d.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	matches := reVersion.FindStringSubmatch(string(resp[1]))
-	if matches != nil {
-		m.Version = &matches[1]
-		n, _ := strconv.ParseUint(matches[2], 10, 32)
-		m.DB = &db{
-			Version: uint32(n),
-			Time:    matches[3],
-		}
-	}
-	for _, poolMatches := range rePool.FindAllStringSubmatch(string(resp[2]), -1) {
-		var pool pool
-		for _, s := range strings.Split(poolMatches[1], " ") {
-			if _, ok := states[s]; ok {
-				pool.State = s
-			} else if s == "PRIMARY" {
-				pool.Primary = true
+}
+
+// transient reports whether err may be fixed by retrying the scrape,
+// e.g. the daemon is restarting or too busy to reply in time.
+// Unexpected replies and TLS handshake failures aren't retried.
+func transient(err error) bool {
+	if errors.Is(err, clamd.ErrUnexpectedReply) {
+		return false
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		// The Unix socket is removed while the daemon restarts.
+		errors.Is(err, syscall.ENOENT) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sessionSettings are the exporter settings a session is opened with.
+type sessionSettings struct {
+	address   string
+	timeout   time.Duration
+	tlsConfig *tls.Config
+}
+
+// session is an IDSESSION session kept between scrapes.
+type session struct {
+	*clamd.Session
+	settings sessionSettings
+	// n is the number of bytes read in the session.
+	n *atomic.Int64
+}
+
+func (e *Exporter) openSession(ctx context.Context, settings sessionSettings) (*session, error) {
+	address, err := url.Parse(settings.address)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &countingDialer{Dialer: net.Dialer{Timeout: settings.timeout}}
+	client := clamd.NewClient(address, clamd.WithTimeout(settings.timeout), clamd.WithTLSConfig(settings.tlsConfig), clamd.WithDialer(dialer))
+	s, err := client.Session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &session{Session: s, settings: settings, n: &dialer.n}, nil
+}
+
+// querySession queries ClamAV in a new session. If persistent, the session
+// is kept for the next queries. A kept session that fails, e.g. because
+// the daemon closed it after its IdleTimeout, is replaced with a new one
+// without failing the query. It returns the number of bytes read.
+func (e *Exporter) querySession(ctx context.Context, settings sessionSettings, persistent, scan bool) (*replies, int64, error) {
+	persistent = persistent && !e.closed
+	if s := e.session; s != nil {
+		e.session = nil
+		if persistent && s.settings == settings {
+			s.n.Store(0)
+			r, err := e.query(ctx, s.Session, scan)
+			if err == nil {
+				e.session = s
+				return r, s.n.Load(), nil
+			}
+			if done(ctx) {
+				s.Close()
+				return nil, s.n.Load(), err
 			}
+			e.logger.Debug("Reconnecting to clamd", "err", err)
+			e.sessionReconnects.Inc()
+		}
+		s.Close()
+	}
+	s, err := e.openSession(ctx, settings)
+	if err != nil {
+		return nil, 0, err
+	}
+	r, err := e.query(ctx, s.Session, scan)
+	if err != nil || !persistent {
+		s.Close()
+	} else {
+		e.session = s
+	}
+	return r, s.n.Load(), err
+}
+
+// replies are the ClamAV daemon replies of a scrape.
+type replies struct {
+	Version      *clamd.Version
+	Stats        []byte
+	Scan         *clamd.Result
+	ScanDuration time.Duration
+}
+
+// query sends the commands in the session.
+// Unexpected replies to the commands other than PING are counted as parse errors, logged and skipped.
+func (e *Exporter) query(ctx context.Context, s *clamd.Session, scan bool) (*replies, error) {
+	if err := s.Ping(ctx); err != nil {
+		return nil, err
+	}
+	var (
+		r   replies
+		err error
+	)
+	if r.Version, err = s.Version(ctx); err != nil {
+		if !errors.Is(err, clamd.ErrUnexpectedReply) {
+			return nil, err
 		}
-		for _, statMatches := range reThreadStat.FindAllStringSubmatch(poolMatches[2], -1) {
-			n, _ := strconv.ParseInt(statMatches[2], 10, 64)
-			switch statMatches[1] {
-			case "live":
-				pool.Threads.Live = &n
-			case "idle":
-				pool.Threads.Idle = &n
-			case "max":
-				pool.Threads.Max = &n
-			case "idle-timeout":
-				pool.Threads.IdleTimeout = &n
+		e.scrapeErrors.WithLabelValues("parse").Inc()
+		e.logger.Error("Unexpected VERSION response", "err", err)
+	}
+	if r.Stats, err = s.Stats(ctx); err != nil {
+		return nil, err
+	}
+	if scan {
+		start := time.Now()
+		if r.Scan, err = s.Instream(ctx, strings.NewReader(eicar())); err != nil {
+			if !errors.Is(err, clamd.ErrUnexpectedReply) {
+				return nil, err
 			}
+			e.scrapeErrors.WithLabelValues("parse").Inc()
+			e.logger.Error("Unexpected INSTREAM response", "err", err)
+			r.Scan = &clamd.Result{Status: clamd.StatusError}
 		}
-		matches = reQueue.FindStringSubmatch(poolMatches[3])
-		if matches != nil {
-			pool.Queue.Length, _ = strconv.ParseInt(matches[1], 10, 64)
-			pool.Queue.MinWait, _ = strconv.ParseFloat(matches[2], 64)
-			pool.Queue.MaxWait, _ = strconv.ParseFloat(matches[3], 64)
-			pool.Queue.AvgWait, _ = strconv.ParseFloat(matches[4], 64)
-		}
-		m.Pools = append(m.Pools, pool)
-	}
-	matches = reMemStats.FindStringSubmatch(string(resp[2]))
-	if matches != nil {
-		for _, statMatches := range reMemStat.FindAllStringSubmatch(matches[1], -1) {
-			f, _ := strconv.ParseFloat(statMatches[2], 64)
-			n := uint64(f * 1024 * 1024)
-			switch statMatches[1] {
-			case "heap":
-				m.Memory.Heap = &n
-			case "mmap":
-				m.Memory.Mmap = &n
-			case "used":
-				m.Memory.Used = &n
-			case "free":
-				m.Memory.Free = &n
-			case "releasable":
-				m.Memory.Releasable = &n
-			case "pools_used":
-				m.Memory.PoolsUsed = &n
-			case "pools_total":
-				m.Memory.PoolsTotal = &n
+		r.ScanDuration = time.Since(start)
+	}
+	return &r, nil
+}
+
+// done reports whether ctx is done. Its deadline may pass before it's done,
+// failing the connection to ClamAV first.
+func done(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// scrapeStage returns the scrape stage that failed with err.
+// Errors that aren't from the ClamAV daemon connection, e.g. an invalid address, are "other".
+func scrapeStage(err error) string {
+	var cerr *clamd.Error
+	if !errors.As(err, &cerr) {
+		return "other"
+	}
+	if cerr.Cmd == "PING" && errors.Is(err, clamd.ErrUnexpectedReply) {
+		return "ping"
+	}
+	return cerr.Op
+}
+
+func (e *Exporter) parseReplies(r *replies) (m metrics) {
+	if r.Version != nil {
+		m.Version = &r.Version.Engine
+		if r.Version.DatabaseTime != "" {
+			m.DB = &db{
+				Version: r.Version.Database,
+				Time:    r.Version.DatabaseTime,
 			}
 		}
 	}
-	ok = true
+	if stats, err := clamd.ParseStats(r.Stats); err != nil {
+		e.scrapeErrors.WithLabelValues("parse").Inc()
+		e.logger.Error("Failed to parse STATS response", "err", err)
+	} else {
+		for _, warning := range stats.Warnings {
+			e.logger.Debug("Unrecognized STATS response content", "warning", warning)
+		}
+		e.statsWarnings.Add(float64(len(stats.Warnings)))
+		m.Pools, m.Memory = stats.Pools, stats.Memory
+	}
+	if r.Scan != nil {
+		m.ScanProbe = &scanProbe{Duration: r.ScanDuration.Seconds()}
+		if r.Scan.Status == clamd.StatusFound {
+			m.ScanProbe.Signature = r.Scan.Signature
+		}
+	}
 	return
 }
 
 func (e *Exporter) collect(m metrics, ch chan<- prometheus.Metric) {
-	if m.Version != nil {
+	if m.Version != nil && e.enabled(CollectorVersion) {
 		ch <- prometheus.MustNewConstMetric(e.version, prometheus.GaugeValue, float64(1), *m.Version)
 	}
-	if m.DB != nil {
+	if m.DB != nil && e.enabled(CollectorVersion) {
 		ch <- prometheus.MustNewConstMetric(e.dbVersion, prometheus.GaugeValue, float64(m.DB.Version))
-		t, err := time.ParseInLocation("Mon Jan _2 15:04:05 2006", m.DB.Time, tz)
+		t, err := m.DB.time()
 		if err != nil {
 			ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
 			e.logger.Error("Failed to parse database time", "time", m.DB.Time, "err", err)
 			return
 		}
 		ch <- prometheus.MustNewConstMetric(e.dbTime, prometheus.GaugeValue, float64(t.Unix()))
+		age := now().Sub(t)
+		ch <- prometheus.MustNewConstMetric(e.dbAge, prometheus.GaugeValue, age.Seconds())
+		e.mu.Lock()
+		maxAge := e.dbMaxAge
+		e.mu.Unlock()
+		if maxAge > 0 {
+			var stale float64
+			if age > maxAge {
+				stale = 1
+			}
+			ch <- prometheus.MustNewConstMetric(e.dbStale, prometheus.GaugeValue, stale)
+		}
+	}
+	if e.enabled(CollectorPools) {
+		e.collectPools(m.Pools, ch)
+	}
+	if e.enabled(CollectorMemory) {
+		e.collectMemory(m.Memory, ch)
 	}
-	for i, pool := range m.Pools {
+	if m.ScanProbe != nil {
+		success := 0.0
+		if m.ScanProbe.Signature != "" {
+			success = 1
+		}
+		ch <- prometheus.MustNewConstMetric(e.scanProbeSuccess, prometheus.GaugeValue, success, m.ScanProbe.Signature)
+		ch <- prometheus.MustNewConstMetric(e.scanProbeDuration, prometheus.GaugeValue, m.ScanProbe.Duration)
+	}
+	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
+}
+
+func (e *Exporter) collectPools(pools []clamd.Pool, ch chan<- prometheus.Metric) {
+	for i, pool := range pools {
 		primary := "0"
 		if pool.Primary {
 			primary = "1"
@@ -294,73 +739,210 @@ func (e *Exporter) collect(m metrics, ch chan<- prometheus.Metric) {
 		if pool.Threads.IdleTimeout != nil {
 			ch <- prometheus.MustNewConstMetric(e.poolIdleTimeoutThreads, prometheus.GaugeValue, float64(*pool.Threads.IdleTimeout), labelValues...)
 		}
-		ch <- prometheus.MustNewConstMetric(e.poolQueueLength, prometheus.GaugeValue, float64(pool.Queue.Length), labelValues...)
-		ch <- prometheus.MustNewConstMetric(e.poolQueueMinWait, prometheus.GaugeValue, pool.Queue.MinWait, labelValues...)
-		ch <- prometheus.MustNewConstMetric(e.poolQueueMaxWait, prometheus.GaugeValue, pool.Queue.MaxWait, labelValues...)
-		ch <- prometheus.MustNewConstMetric(e.poolQueueAvgWait, prometheus.GaugeValue, pool.Queue.AvgWait, labelValues...)
+		if pool.Queue != nil {
+			ch <- prometheus.MustNewConstMetric(e.poolQueueLength, prometheus.GaugeValue, float64(pool.Queue.Length), labelValues...)
+			ch <- prometheus.MustNewConstMetric(e.poolQueueMinWait, prometheus.GaugeValue, pool.Queue.MinWait, labelValues...)
+			ch <- prometheus.MustNewConstMetric(e.poolQueueMaxWait, prometheus.GaugeValue, pool.Queue.MaxWait, labelValues...)
+			ch <- prometheus.MustNewConstMetric(e.poolQueueAvgWait, prometheus.GaugeValue, pool.Queue.AvgWait, labelValues...)
+		}
+		var names []string
+		counts := make(map[string]int)
+		maxElapsed := make(map[string]float64)
+		for _, cmd := range pool.Commands {
+			if _, ok := counts[cmd.Name]; !ok {
+				names = append(names, cmd.Name)
+			}
+			counts[cmd.Name]++
+			maxElapsed[cmd.Name] = max(maxElapsed[cmd.Name], cmd.Elapsed)
+		}
+		for _, name := range names {
+			ch <- prometheus.MustNewConstMetric(e.poolActiveCommands, prometheus.GaugeValue, float64(counts[name]), append(labelValues, name)...)
+			ch <- prometheus.MustNewConstMetric(e.poolActiveCommandMaxTime, prometheus.GaugeValue, maxElapsed[name], append(labelValues, name)...)
+		}
 	}
-	if m.Memory.Heap != nil {
-		ch <- prometheus.MustNewConstMetric(e.heapMemory, prometheus.GaugeValue, float64(*m.Memory.Heap))
+}
+
+func (e *Exporter) collectMemory(memory clamd.Memory, ch chan<- prometheus.Metric) {
+	if memory.Heap != nil {
+		ch <- prometheus.MustNewConstMetric(e.heapMemory, prometheus.GaugeValue, float64(*memory.Heap))
 	}
-	if m.Memory.Mmap != nil {
-		ch <- prometheus.MustNewConstMetric(e.mmapMemory, prometheus.GaugeValue, float64(*m.Memory.Mmap))
+	if memory.Mmap != nil {
+		ch <- prometheus.MustNewConstMetric(e.mmapMemory, prometheus.GaugeValue, float64(*memory.Mmap))
 	}
-	if m.Memory.Used != nil {
-		ch <- prometheus.MustNewConstMetric(e.usedMemory, prometheus.GaugeValue, float64(*m.Memory.Used))
+	if memory.Used != nil {
+		ch <- prometheus.MustNewConstMetric(e.usedMemory, prometheus.GaugeValue, float64(*memory.Used))
 	}
-	if m.Memory.Free != nil {
-		ch <- prometheus.MustNewConstMetric(e.freeMemory, prometheus.GaugeValue, float64(*m.Memory.Free))
+	if memory.Free != nil {
+		ch <- prometheus.MustNewConstMetric(e.freeMemory, prometheus.GaugeValue, float64(*memory.Free))
 	}
-	if m.Memory.Releasable != nil {
-		ch <- prometheus.MustNewConstMetric(e.releasableMemory, prometheus.GaugeValue, float64(*m.Memory.Releasable))
+	if memory.Releasable != nil {
+		ch <- prometheus.MustNewConstMetric(e.releasableMemory, prometheus.GaugeValue, float64(*memory.Releasable))
 	}
-	if m.Memory.PoolsUsed != nil {
-		ch <- prometheus.MustNewConstMetric(e.poolsUsedMemory, prometheus.GaugeValue, float64(*m.Memory.PoolsUsed))
+	if memory.PoolsUsed != nil {
+		ch <- prometheus.MustNewConstMetric(e.poolsUsedMemory, prometheus.GaugeValue, float64(*memory.PoolsUsed))
 	}
-	if m.Memory.PoolsTotal != nil {
-		ch <- prometheus.MustNewConstMetric(e.poolsTotalMemory, prometheus.GaugeValue, float64(*m.Memory.PoolsTotal))
+	if memory.PoolsTotal != nil {
+		ch <- prometheus.MustNewConstMetric(e.poolsTotalMemory, prometheus.GaugeValue, float64(*memory.PoolsTotal))
 	}
-	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
 }
 
-func parseResponse(data []byte, resp [][]byte) error {
-	for {
-		if len(data) == 0 {
-			return nil
-		}
-		i := bytes.Index(data, []byte(": "))
-		if i == -1 {
-			return errors.New("failed to find response ID")
+func (e *Exporter) collectPublished(p *published, local *db, ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(e.publishedVersion, prometheus.GaugeValue, 1, p.Engine)
+	ch <- prometheus.MustNewConstMetric(e.publishedDBVersion, prometheus.GaugeValue, float64(p.Main), "main")
+	ch <- prometheus.MustNewConstMetric(e.publishedDBVersion, prometheus.GaugeValue, float64(p.Daily), "daily")
+	ch <- prometheus.MustNewConstMetric(e.publishedDBVersion, prometheus.GaugeValue, float64(p.Bytecode), "bytecode")
+	ch <- prometheus.MustNewConstMetric(e.publishedDBTime, prometheus.GaugeValue, float64(p.Time.Unix()))
+	if local != nil {
+		// The VERSION reply has the daily database version.
+		var behind float64
+		if p.Daily > local.Version {
+			behind = float64(p.Daily - local.Version)
 		}
-		n, err := strconv.ParseInt(string(data[:i]), 10, 64)
-		if err != nil {
-			return errors.New("invalid response ID: " + err.Error())
+		ch <- prometheus.MustNewConstMetric(e.dbVersionsBehind, prometheus.GaugeValue, behind)
+	}
+}
+
+func (e *Exporter) enabled(collector string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enabledLocked(collector)
+}
+
+func (e *Exporter) enabledLocked(collector string) bool {
+	if e.collectors == nil {
+		return slices.Contains(DefaultCollectors, collector)
+	}
+	return e.collectors[collector]
+}
+
+// countingDialer dials connections counting the bytes read from them.
+type countingDialer struct {
+	net.Dialer
+	n atomic.Int64
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, n: &d.n}, nil
+}
+
+type countingConn struct {
+	net.Conn
+	n *atomic.Int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// Poll scrapes ClamAV every interval until ctx is done. While polling,
+// Collect delivers the last polled statistics instead of scraping ClamAV,
+// or reports ClamAV down until the first poll finishes.
+// Use StartPolling to poll in the background.
+func (e *Exporter) Poll(ctx context.Context, interval time.Duration) {
+	e.polling.Store(true)
+	e.poll(ctx, interval)
+}
+
+// StartPolling starts polling in the background like Poll does. Collect delivers
+// the polled statistics as soon as it returns, never scraping ClamAV itself.
+func (e *Exporter) StartPolling(ctx context.Context, interval time.Duration) {
+	e.polling.Store(true)
+	go e.poll(ctx, interval)
+}
+
+func (e *Exporter) poll(ctx context.Context, interval time.Duration) {
+	defer func() {
+		e.polling.Store(false)
+		e.resultMu.Lock()
+		e.polled = nil
+		e.resultMu.Unlock()
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if r := e.scrapeShared(ctx); !done(ctx) {
+			e.resultMu.Lock()
+			e.polled = r
+			e.resultMu.Unlock()
 		}
-		if n < 1 || n-1 >= int64(len(resp)) {
-			return errors.New("response ID out of range")
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
-		data = data[i+2:]
-		i = bytes.IndexByte(data, '\000')
-		if i == -1 {
-			return errors.New("missing trailing NULL")
+	}
+}
+
+// Configure atomically replaces the ClamAV daemon address and settings
+// of the exporter. An in-flight scrape keeps using the old settings.
+// A persistent session is reopened with the new settings on the next scrape.
+func (e *Exporter) Configure(address *url.URL, timeout time.Duration, retries int, opts ...Option) error {
+	if retries < 0 {
+		return fmt.Errorf("invalid retry count %d", retries)
+	}
+	c := &Exporter{
+		retryBackoff:    DefaultRetryBackoff,
+		maxRetryBackoff: DefaultMaxRetryBackoff,
+		publishedRecord: DefaultPublishedRecord,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return err
 		}
-		resp[n-1] = data[:i]
-		data = data[i+1:]
 	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.address = address
+	e.timeout = timeout
+	e.retries = retries
+	e.tlsConfig = c.tlsConfig
+	e.collectors = c.collectors
+	e.maxResultAge = c.maxResultAge
+	e.dbMaxAge = c.dbMaxAge
+	e.persistentSession = c.persistentSession
+	e.retryBackoff = c.retryBackoff
+	e.maxRetryBackoff = c.maxRetryBackoff
+	e.publishedRecord = c.publishedRecord
+	e.resolver = c.resolver
+	return nil
+}
+
+// Close closes the persistent session, if any. The scrapes after Close
+// don't keep their sessions open anymore.
+func (e *Exporter) Close() error {
+	e.sessionMu.Lock()
+	defer e.sessionMu.Unlock()
+	e.closed = true
+	if e.session == nil {
+		return nil
+	}
+	err := e.session.Close()
+	e.session = nil
+	return err
 }
 
 // New returns an initialized exporter.
-func New(address *url.URL, timeout time.Duration, retries int, logger *slog.Logger) (*Exporter, error) {
+func New(address *url.URL, timeout time.Duration, retries int, logger *slog.Logger, opts ...Option) (*Exporter, error) {
 	if retries < 0 {
 		return nil, fmt.Errorf("invalid retry count %d", retries)
 	}
-	return &Exporter{
+	e := &Exporter{
 		scrape:  (*Exporter).scrapeSocket,
 		address: address,
 		timeout: timeout,
 		retries: retries,
 		logger:  logger,
 
+		retryBackoff:    DefaultRetryBackoff,
+		maxRetryBackoff: DefaultMaxRetryBackoff,
+		publishedRecord: DefaultPublishedRecord,
+
 		up: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "up"),
 			"Was the last scrape successful.",
@@ -385,6 +967,18 @@ func New(address *url.URL, timeout time.Duration, retries int, logger *slog.Logg
 			nil,
 			nil,
 		),
+		dbAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "db_age_seconds"),
+			"Age of the ClamAV Virus Database in seconds.",
+			nil,
+			nil,
+		),
+		dbStale: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "db_stale"),
+			"Whether the ClamAV Virus Database is older than the max age.",
+			nil,
+			nil,
+		),
 		poolState: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "pool_state"),
 			"State of the thread pool.",
@@ -439,6 +1033,18 @@ func New(address *url.URL, timeout time.Duration, retries int, logger *slog.Logg
 			[]string{"index", "primary"},
 			nil,
 		),
+		poolActiveCommands: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pool_active_commands"),
+			"Number of commands currently processed in the pool.",
+			[]string{"index", "primary", "command"},
+			nil,
+		),
+		poolActiveCommandMaxTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pool_active_command_max_seconds"),
+			"Maximum time a command currently processed in the pool has been running.",
+			[]string{"index", "primary", "command"},
+			nil,
+		),
 		heapMemory: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "memory_heap_bytes"),
 			"Number of bytes allocated on the heap.",
@@ -481,5 +1087,100 @@ func New(address *url.URL, timeout time.Duration, retries int, logger *slog.Logg
 			nil,
 			nil,
 		),
-	}, nil
+		scanProbeSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "scan_probe_success"),
+			"Whether the EICAR test file was detected by the scan probe.",
+			[]string{"signature"},
+			nil,
+		),
+		scanProbeDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "scan_probe_duration_seconds"),
+			"Duration of the scan probe in seconds.",
+			nil,
+			nil,
+		),
+		publishedVersion: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "published", "version"),
+			"The latest published version of ClamAV.",
+			[]string{"version"},
+			nil,
+		),
+		publishedDBVersion: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "published", "db_version"),
+			"The latest published ClamAV Virus Database version.",
+			[]string{"database"},
+			nil,
+		),
+		publishedDBTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "published", "db_timestamp_seconds"),
+			"Unix timestamp of the latest published ClamAV daily database.",
+			nil,
+			nil,
+		),
+		dbVersionsBehind: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "db_versions_behind"),
+			"Number of published daily database versions the installed one is behind.",
+			nil,
+			nil,
+		),
+		lastScrapeTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "last_scrape_timestamp_seconds"),
+			"Unix timestamp of the last scrape of ClamAV.",
+			nil,
+			nil,
+		),
+		lastScrapeAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "last_scrape_age_seconds"),
+			"Number of seconds since the last scrape of ClamAV.",
+			nil,
+			nil,
+		),
+		scrapeDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "scrape_duration_seconds"),
+			"Duration of the last scrape of ClamAV in seconds.",
+			nil,
+			nil,
+		),
+		scrapeResponseSize: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "scrape_response_size_bytes"),
+			"Size of the ClamAV responses of the last scrape in bytes.",
+			nil,
+			nil,
+		),
+		scrapeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "scrape_errors_total",
+			Help:      "Number of ClamAV scrape errors by the failed stage.",
+		}, []string{"stage"}),
+		scrapeRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "scrape_retries_total",
+			Help:      "Number of ClamAV scrape retries.",
+		}),
+		statsWarnings: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stats_parse_warnings_total",
+			Help:      "Number of unrecognized lines and inconsistencies in ClamAV STATS responses.",
+		}),
+		sessionReconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "session_reconnects_total",
+			Help:      "Number of times the persistent ClamAV session failed and was reopened.",
+		}),
+		publishedLookupErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "published",
+			Name:      "lookup_errors_total",
+			Help:      "Number of failed lookups of the published ClamAV versions.",
+		}),
+	}
+	for _, stage := range scrapeStages {
+		e.scrapeErrors.WithLabelValues(stage)
+	}
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
 }
@@ -2,50 +2,86 @@ package exporter
 
 import (
 	"bytes"
+	"context"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/promslog"
+	"github.com/sergeymakinen/clamav_exporter/v2/clamd"
 )
 
 func TestMain(m *testing.M) {
 	tz = time.UTC
+	now = func() time.Time {
+		return time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
 	os.Exit(m.Run())
 }
 
+func TestEicar(t *testing.T) {
+	s := eicar()
+	if len(s) != 68 || !strings.HasPrefix(s, `X5O!P%@AP`) || !strings.HasSuffix(s, `$H+H*`) {
+		t.Fatalf("eicar() = %q; want the EICAR test file", s)
+	}
+	if strings.Contains(eicarReversed, s) {
+		t.Error("eicarReversed contains the EICAR test file")
+	}
+	name := filepath.Join(t.TempDir(), "clamav_exporter")
+	if out, err := exec.Command("go", "build", "-o", name, "../cmd/clamav_exporter").CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte(s)) {
+		t.Error("exporter binary contains the EICAR test file")
+	}
+}
+
 func TestExporter_Collect(t *testing.T) {
 	exporter, err := New(nil, 0, 0, promslog.NewNopLogger())
 	if err != nil {
 		t.Fatalf("New() = _, %v; want nil", err)
 	}
 	version := "1.2.3"
-	exporter.scrape = func(e *Exporter) (m metrics, ok bool) {
+	exporter.scrape = func(e *Exporter, _ context.Context) (m metrics, ok bool) {
 		return metrics{
 			Version: &version,
 			DB: &db{
 				Version: 123,
 				Time:    "Fri Nov 19 09:19:46 2021",
 			},
-			Pools: []pool{
+			Pools: []clamd.Pool{
 				{
 					State:   "EXIT",
 					Primary: true,
-					Threads: threads{
+					Threads: clamd.Threads{
 						Live:        newInt64(124),
 						Max:         newInt64(125),
 						IdleTimeout: newInt64(126),
 					},
-					Queue: queue{
+					Queue: &clamd.Queue{
 						Length:  127,
 						MinWait: 0.131,
 						MaxWait: 0.132,
 						AvgWait: 0.133,
 					},
+					Commands: []clamd.Command{
+						{Name: "STATS", Elapsed: 0.001},
+						{Name: "INSTREAM", Elapsed: 1.5},
+						{Name: "INSTREAM", Elapsed: 2.5},
+					},
 				},
 			},
-			Memory: memory{
+			Memory: clamd.Memory{
 				Heap:       newUint64(128),
 				Mmap:       newUint64(0),
 				PoolsUsed:  newUint64(129 * 1024),
@@ -62,5 +98,271 @@ func TestExporter_Collect(t *testing.T) {
 	}
 }
 
+func TestExporter_Collect_Collectors(t *testing.T) {
+	if _, err := New(nil, 0, 0, promslog.NewNopLogger(), WithCollectors("foo")); err == nil {
+		t.Error("New() = _, nil; want error")
+	}
+	exporter, err := New(nil, 0, 0, promslog.NewNopLogger(), WithCollectors(CollectorVersion, CollectorMemory))
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	version := "1.2.3"
+	exporter.scrape = func(e *Exporter, _ context.Context) (m metrics, ok bool) {
+		return metrics{
+			Version: &version,
+			Pools: []clamd.Pool{
+				{
+					State: "VALID",
+				},
+			},
+			Memory: clamd.Memory{
+				Heap: newUint64(128),
+			},
+		}, true
+	}
+	if n := testutil.CollectAndCount(exporter, "clamav_up", "clamav_version", "clamav_memory_heap_bytes"); n != 3 {
+		t.Errorf("testutil.CollectAndCount() = %d; want 3", n)
+	}
+}
+
+func TestExporter_Poll(t *testing.T) {
+	exporter, err := New(nil, 0, 0, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	var scrapes atomic.Int32
+	version := "1.2.3"
+	exporter.scrape = func(e *Exporter, _ context.Context) (m metrics, ok bool) {
+		scrapes.Add(1)
+		return metrics{Version: &version}, true
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		exporter.Poll(ctx, time.Hour)
+		close(done)
+	}()
+	for i := 0; ; i++ {
+		exporter.resultMu.Lock()
+		r := exporter.result
+		exporter.resultMu.Unlock()
+		if r != nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Poll() didn't scrape")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	want := `# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 1
+# HELP clamav_version The version of this ClamAV.
+# TYPE clamav_version gauge
+clamav_version{version="1.2.3"} 1
+`
+	for i := 0; i < 2; i++ {
+		if err = testutil.CollectAndCompare(exporter, strings.NewReader(want), "clamav_up", "clamav_version"); err != nil {
+			t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+		}
+	}
+	if n := testutil.CollectAndCount(exporter, "clamav_exporter_last_scrape_timestamp_seconds", "clamav_exporter_last_scrape_age_seconds"); n != 2 {
+		t.Errorf("testutil.CollectAndCount() = %d; want 2", n)
+	}
+	if n := scrapes.Load(); n != 1 {
+		t.Errorf("scrapes = %d; want 1", n)
+	}
+	cancel()
+	<-done
+}
+
+func TestExporter_StartPolling(t *testing.T) {
+	exporter, err := New(nil, 0, 0, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	var scrapes atomic.Int32
+	release := make(chan struct{})
+	version := "1.2.3"
+	exporter.scrape = func(e *Exporter, _ context.Context) (m metrics, ok bool) {
+		scrapes.Add(1)
+		<-release
+		return metrics{Version: &version}, true
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exporter.StartPolling(ctx, time.Hour)
+	down := `# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 0
+`
+	if err = testutil.CollectAndCompare(exporter, strings.NewReader(down), "clamav_up"); err != nil {
+		t.Errorf("testutil.CollectAndCompare() before the first poll = %v; want nil", err)
+	}
+	close(release)
+	for i := 0; ; i++ {
+		exporter.resultMu.Lock()
+		r := exporter.polled
+		exporter.resultMu.Unlock()
+		if r != nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("StartPolling() didn't scrape")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	up := `# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 1
+`
+	if err = testutil.CollectAndCompare(exporter, strings.NewReader(up), "clamav_up"); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+	if n := scrapes.Load(); n > 1 {
+		t.Errorf("scrapes = %d; want 1", n)
+	}
+}
+
+func TestExporter_Collect_Concurrent(t *testing.T) {
+	exporter, err := New(nil, 0, 0, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	var scrapes atomic.Int32
+	release := make(chan struct{})
+	exporter.scrape = func(e *Exporter, _ context.Context) (m metrics, ok bool) {
+		scrapes.Add(1)
+		<-release
+		return metrics{}, true
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			testutil.CollectAndCount(exporter, "clamav_up")
+		}()
+	}
+	// Let all the collects join the in-flight scrape.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := scrapes.Load(); n != 1 {
+		t.Errorf("scrapes = %d; want 1", n)
+	}
+}
+
+func TestExporter_Collect_ConcurrentCanceled(t *testing.T) {
+	exporter, err := New(nil, 0, 0, promslog.NewNopLogger())
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	exporter.scrape = func(e *Exporter, ctx context.Context) (m metrics, ok bool) {
+		close(started)
+		select {
+		case <-release:
+			return metrics{}, true
+		case <-ctx.Done():
+			return metrics{}, false
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		first <- testutil.CollectAndCompare(exporter.WithContext(ctx), strings.NewReader(`# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 0
+`), "clamav_up")
+	}()
+	<-started
+	second := make(chan error)
+	go func() {
+		second <- testutil.CollectAndCompare(exporter, strings.NewReader(`# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 1
+`), "clamav_up")
+	}()
+	// Let the second collect join the in-flight scrape.
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err = <-first; err != nil {
+		t.Errorf("testutil.CollectAndCompare() of the canceled caller = %v; want nil", err)
+	}
+	close(release)
+	if err = <-second; err != nil {
+		t.Errorf("testutil.CollectAndCompare() of the waiting caller = %v; want nil", err)
+	}
+}
+
+func TestExporter_Collect_MaxResultAge(t *testing.T) {
+	tests := []struct {
+		maxAge time.Duration
+		want   int32
+	}{
+		{maxAge: 0, want: 2},
+		{maxAge: time.Hour, want: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.maxAge.String(), func(t *testing.T) {
+			exporter, err := New(nil, 0, 0, promslog.NewNopLogger(), WithMaxResultAge(test.maxAge))
+			if err != nil {
+				t.Fatalf("New() = _, %v; want nil", err)
+			}
+			var scrapes atomic.Int32
+			exporter.scrape = func(e *Exporter, _ context.Context) (m metrics, ok bool) {
+				scrapes.Add(1)
+				return metrics{}, true
+			}
+			for i := 0; i < 2; i++ {
+				testutil.CollectAndCount(exporter, "clamav_up")
+			}
+			if n := scrapes.Load(); n != test.want {
+				t.Errorf("scrapes = %d; want %d", n, test.want)
+			}
+		})
+	}
+}
+
+func TestExporter_Health(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		up      bool
+		stale   string
+		wantErr bool
+	}{
+		{name: "down", up: false, wantErr: true},
+		{name: "no max age", up: true},
+		{name: "fresh", maxAge: 24 * time.Hour, up: true, stale: "0"},
+		{name: "stale", maxAge: time.Hour, up: true, stale: "1", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter, err := New(nil, 0, 0, promslog.NewNopLogger(), WithDBMaxAge(test.maxAge))
+			if err != nil {
+				t.Fatalf("New() = _, %v; want nil", err)
+			}
+			exporter.scrape = func(e *Exporter, _ context.Context) (m metrics, ok bool) {
+				return metrics{DB: &db{Version: 123, Time: "Tue Dec 31 12:00:00 2024"}}, test.up
+			}
+			if err = exporter.Health(context.Background()); (err != nil) != test.wantErr {
+				t.Errorf("Health() = %v; want error: %t", err, test.wantErr)
+			}
+			want := ""
+			if test.stale != "" {
+				want = `# HELP clamav_db_stale Whether the ClamAV Virus Database is older than the max age.
+# TYPE clamav_db_stale gauge
+clamav_db_stale ` + test.stale + "\n"
+			}
+			if err = testutil.CollectAndCompare(exporter, strings.NewReader(want), "clamav_db_stale"); err != nil {
+				t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+			}
+		})
+	}
+}
+
 func newInt64(n int64) *int64    { return &n }
 func newUint64(n uint64) *uint64 { return &n }
@@ -0,0 +1,189 @@
+package exporter
+
+import (
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	reFreshclamStart    = regexp.MustCompile(`ClamAV update process started`)
+	reFreshclamDatabase = regexp.MustCompile(`^(\w+)\.c[vl]d (?:database is up[ -]to[ -]date|updated) \(version: (\d+)`)
+)
+
+// freshclamFailures maps the update failure reasons to the patterns of the freshclam log messages.
+// The first matching pattern wins.
+var freshclamFailures = []struct {
+	Reason  string
+	Pattern *regexp.Regexp
+}{
+	{Reason: "cooldown", Pattern: regexp.MustCompile(`(?i)cool-?down|previously received error code`)},
+	{Reason: "rate_limited", Pattern: regexp.MustCompile(`\b429\b|Too Many Requests`)},
+	{Reason: "forbidden", Pattern: regexp.MustCompile(`\b403\b|Forbidden`)},
+	{Reason: "connection", Pattern: regexp.MustCompile(`(?i)can't connect|connection (?:failed|refused|reset|timed out)|could(?: not|n't) (?:connect|resolve)|failed to connect|timeout was reached`)},
+	{Reason: "invalid_database", Pattern: regexp.MustCompile(`(?i)invalid CVD|can't verify database integrity|verification|database test failed|not synchronized|corrupt|incremental update failed`)},
+}
+
+// freshclamPriorities rank the failure reasons, so a failed update run is counted
+// under its most specific reason. A cooldown follows rate limiting, so it ranks lower.
+var freshclamPriorities = map[string]int{
+	"rate_limited":     5,
+	"forbidden":        4,
+	"connection":       3,
+	"invalid_database": 2,
+	"cooldown":         1,
+	"other":            0,
+}
+
+// FreshclamCollector collects database update stats from the freshclam log file
+// and exports them using the prometheus metrics package.
+type FreshclamCollector struct {
+	tailer *tailer
+	logger *slog.Logger
+	mu     sync.Mutex
+
+	attempts    float64
+	successes   float64
+	failures    map[string]float64
+	lastSuccess float64
+	versions    map[string]float64
+	// State of the current update run.
+	succeeded bool
+	// failure is the reason of the run failure or empty if it hasn't failed.
+	failure string
+	counted bool
+
+	attemptsDesc    *prometheus.Desc
+	successesDesc   *prometheus.Desc
+	failuresDesc    *prometheus.Desc
+	lastSuccessDesc *prometheus.Desc
+	versionDesc     *prometheus.Desc
+}
+
+// Describe describes all the metrics exported by the collector. It
+// implements prometheus.Collector.
+func (c *FreshclamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.attemptsDesc
+	ch <- c.successesDesc
+	ch <- c.failuresDesc
+	ch <- c.lastSuccessDesc
+	ch <- c.versionDesc
+}
+
+// Collect reads the lines appended to the log file, and
+// delivers the update stats as Prometheus metrics. It implements prometheus.Collector.
+func (c *FreshclamCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.tailer.lines(c.parse); err != nil {
+		c.logger.Error("Failed to read freshclam log", "file", c.tailer.name, "err", err)
+	}
+	c.countFailure()
+	ch <- prometheus.MustNewConstMetric(c.attemptsDesc, prometheus.CounterValue, c.attempts)
+	ch <- prometheus.MustNewConstMetric(c.successesDesc, prometheus.CounterValue, c.successes)
+	for _, failure := range freshclamFailures {
+		ch <- prometheus.MustNewConstMetric(c.failuresDesc, prometheus.CounterValue, c.failures[failure.Reason], failure.Reason)
+	}
+	ch <- prometheus.MustNewConstMetric(c.failuresDesc, prometheus.CounterValue, c.failures["other"], "other")
+	if c.lastSuccess > 0 {
+		ch <- prometheus.MustNewConstMetric(c.lastSuccessDesc, prometheus.GaugeValue, c.lastSuccess)
+	}
+	for db, version := range c.versions {
+		ch <- prometheus.MustNewConstMetric(c.versionDesc, prometheus.GaugeValue, version, db)
+	}
+}
+
+func (c *FreshclamCollector) parse(line string) {
+	t, msg := parseLogLine(line)
+	if reFreshclamStart.MatchString(msg) {
+		c.countFailure()
+		c.attempts++
+		c.succeeded = false
+		c.failure = ""
+		c.counted = false
+		return
+	}
+	if matches := reFreshclamDatabase.FindStringSubmatch(msg); matches != nil {
+		n, _ := strconv.ParseUint(matches[2], 10, 32)
+		c.versions[matches[1]] = float64(n)
+		if !c.succeeded {
+			c.succeeded = true
+			c.successes++
+		}
+		c.lastSuccess = float64(t.Unix())
+		return
+	}
+	isError := strings.HasPrefix(msg, "ERROR: ") || strings.HasPrefix(msg, "!")
+	if !isError && !strings.HasPrefix(msg, "WARNING: ") && !strings.HasPrefix(msg, "^") {
+		return
+	}
+	reason := "other"
+	for _, failure := range freshclamFailures {
+		if failure.Pattern.MatchString(msg) {
+			reason = failure.Reason
+			break
+		}
+	}
+	if reason == "other" && !isError {
+		return
+	}
+	// Failures are usually logged using multiple lines, so only the most specific reason is kept.
+	if c.failure == "" || freshclamPriorities[reason] > freshclamPriorities[c.failure] {
+		c.failure = reason
+	}
+}
+
+// countFailure counts the failure of the current update run once it ends
+// or, as it may be still running, once all the logged lines are read.
+// The lines of the run logged after that don't change its reason.
+func (c *FreshclamCollector) countFailure() {
+	if c.failure != "" && !c.counted {
+		c.failures[c.failure]++
+		c.counted = true
+	}
+}
+
+// NewFreshclamCollector returns an initialized freshclam log collector.
+func NewFreshclamCollector(logFile string, logger *slog.Logger) *FreshclamCollector {
+	return &FreshclamCollector{
+		tailer:   &tailer{name: logFile},
+		logger:   logger,
+		failures: make(map[string]float64),
+		versions: make(map[string]float64),
+
+		attemptsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "freshclam", "update_attempts_total"),
+			"Number of database update attempts.",
+			nil,
+			nil,
+		),
+		successesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "freshclam", "update_successes_total"),
+			"Number of successful database update attempts.",
+			nil,
+			nil,
+		),
+		failuresDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "freshclam", "update_failures_total"),
+			"Number of failed database update attempts by reason.",
+			[]string{"reason"},
+			nil,
+		),
+		lastSuccessDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "freshclam", "last_success_timestamp_seconds"),
+			"Unix timestamp of the last successful database update.",
+			nil,
+			nil,
+		),
+		versionDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "freshclam", "database_version"),
+			"Version of the database reported by freshclam.",
+			[]string{"database"},
+			nil,
+		),
+	}
+}
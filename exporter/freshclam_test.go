@@ -0,0 +1,24 @@
+package exporter
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestFreshclamCollector_Collect(t *testing.T) {
+	collector := NewFreshclamCollector("testdata/freshclam.log", promslog.NewNopLogger())
+	b, err := os.ReadFile("testdata/freshclam-metrics.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Collect twice to check the log lines are counted once.
+	for i := 0; i < 2; i++ {
+		if err := testutil.CollectAndCompare(collector, bytes.NewReader(b)); err != nil {
+			t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// freshclamDatUUIDSize is the size of the NULL-terminated UUID string in freshclam.dat.
+const freshclamDatUUIDSize = 37
+
+// FreshclamDatCollector collects the freshclam state from the freshclam.dat file
+// and exports it using the prometheus metrics package.
+type FreshclamDatCollector struct {
+	name   string
+	logger *slog.Logger
+
+	info       *prometheus.Desc
+	retryAfter *prometheus.Desc
+	blocked    *prometheus.Desc
+}
+
+// Describe describes all the metrics exported by the collector. It
+// implements prometheus.Collector.
+func (c *FreshclamDatCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	ch <- c.retryAfter
+	ch <- c.blocked
+}
+
+// Collect reads the freshclam state, and
+// delivers it as Prometheus metrics. It implements prometheus.Collector.
+func (c *FreshclamDatCollector) Collect(ch chan<- prometheus.Metric) {
+	dat, err := readFreshclamDat(c.name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.logger.Debug("freshclam.dat doesn't exist", "file", c.name)
+		} else {
+			c.logger.Error("Failed to read freshclam.dat", "file", c.name, "err", err)
+		}
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, 1, dat.UUID)
+	var retryAfter, blocked float64
+	if !dat.RetryAfter.IsZero() {
+		retryAfter = float64(dat.RetryAfter.Unix())
+		if time.Now().Before(dat.RetryAfter) {
+			blocked = 1
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.retryAfter, prometheus.GaugeValue, retryAfter)
+	ch <- prometheus.MustNewConstMetric(c.blocked, prometheus.GaugeValue, blocked)
+}
+
+type freshclamDat struct {
+	UUID       string
+	RetryAfter time.Time
+}
+
+// readFreshclamDat decodes the freshclam.dat file. freshclam writes it as
+// a raw C struct in the native byte order:
+//
+//	struct {
+//		uint32_t version;
+//		char uuid[37];
+//		time_t retry_after;
+//	};
+//
+// so the time_t offset and size depend on the platform alignment.
+func readFreshclamDat(name string) (*freshclamDat, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4+freshclamDatUUIDSize {
+		return nil, errors.New("file is too short")
+	}
+	if version := binary.NativeEndian.Uint32(b); version != 1 {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+	uuid := b[4 : 4+freshclamDatUUIDSize]
+	if i := bytes.IndexByte(uuid, 0); i != -1 {
+		uuid = uuid[:i]
+	}
+	dat := &freshclamDat{UUID: string(uuid)}
+	var retryAfter int64
+	switch len(b) {
+	case 56: // 64-bit time_t aligned to 8 bytes.
+		retryAfter = int64(binary.NativeEndian.Uint64(b[48:]))
+	case 52: // 64-bit time_t aligned to 4 bytes.
+		retryAfter = int64(binary.NativeEndian.Uint64(b[44:]))
+	case 48: // 32-bit time_t.
+		retryAfter = int64(int32(binary.NativeEndian.Uint32(b[44:])))
+	default:
+		return nil, fmt.Errorf("unexpected file size %d", len(b))
+	}
+	if retryAfter > 0 {
+		dat.RetryAfter = time.Unix(retryAfter, 0)
+	}
+	return dat, nil
+}
+
+// NewFreshclamDatCollector returns an initialized freshclam.dat collector.
+func NewFreshclamDatCollector(name string, logger *slog.Logger) *FreshclamDatCollector {
+	return &FreshclamDatCollector{
+		name:   name,
+		logger: logger,
+
+		info: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "freshclam", "info"),
+			"Information about the freshclam state.",
+			[]string{"uuid"},
+			nil,
+		),
+		retryAfter: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "freshclam", "retry_after_timestamp_seconds"),
+			"Unix timestamp until which freshclam is on cool-down after being rate limited or blocked by the CDN, 0 if not set.",
+			nil,
+			nil,
+		),
+		blocked: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "freshclam", "blocked"),
+			"Whether freshclam is currently on cool-down and won't update the databases.",
+			nil,
+			nil,
+		),
+	}
+}
@@ -0,0 +1,78 @@
+package exporter
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestFreshclamDatCollector_Collect(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		retryAfter int64
+		want       string
+	}{
+		{
+			name:       "64-bit blocked",
+			size:       56,
+			retryAfter: 4102444800,
+			want: `# HELP clamav_freshclam_blocked Whether freshclam is currently on cool-down and won't update the databases.
+# TYPE clamav_freshclam_blocked gauge
+clamav_freshclam_blocked 1
+# HELP clamav_freshclam_info Information about the freshclam state.
+# TYPE clamav_freshclam_info gauge
+clamav_freshclam_info{uuid="0b1c6f4e-7f37-4d2c-b51c-4a3b2b6d1a90"} 1
+# HELP clamav_freshclam_retry_after_timestamp_seconds Unix timestamp until which freshclam is on cool-down after being rate limited or blocked by the CDN, 0 if not set.
+# TYPE clamav_freshclam_retry_after_timestamp_seconds gauge
+clamav_freshclam_retry_after_timestamp_seconds 4.1024448e+09
+`,
+		},
+		{
+			name:       "32-bit expired",
+			size:       48,
+			retryAfter: 1733737073,
+			want: `# HELP clamav_freshclam_blocked Whether freshclam is currently on cool-down and won't update the databases.
+# TYPE clamav_freshclam_blocked gauge
+clamav_freshclam_blocked 0
+# HELP clamav_freshclam_info Information about the freshclam state.
+# TYPE clamav_freshclam_info gauge
+clamav_freshclam_info{uuid="0b1c6f4e-7f37-4d2c-b51c-4a3b2b6d1a90"} 1
+# HELP clamav_freshclam_retry_after_timestamp_seconds Unix timestamp until which freshclam is on cool-down after being rate limited or blocked by the CDN, 0 if not set.
+# TYPE clamav_freshclam_retry_after_timestamp_seconds gauge
+clamav_freshclam_retry_after_timestamp_seconds 1.733737073e+09
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := make([]byte, test.size)
+			binary.NativeEndian.PutUint32(b, 1)
+			copy(b[4:], "0b1c6f4e-7f37-4d2c-b51c-4a3b2b6d1a90")
+			if test.size == 56 {
+				binary.NativeEndian.PutUint64(b[48:], uint64(test.retryAfter))
+			} else {
+				binary.NativeEndian.PutUint32(b[44:], uint32(test.retryAfter))
+			}
+			name := filepath.Join(t.TempDir(), "freshclam.dat")
+			if err := os.WriteFile(name, b, 0666); err != nil {
+				t.Fatal(err)
+			}
+			collector := NewFreshclamDatCollector(name, promslog.NewNopLogger())
+			if err := testutil.CollectAndCompare(collector, strings.NewReader(test.want)); err != nil {
+				t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+			}
+		})
+	}
+}
+
+func TestReadFreshclamDat_Invalid(t *testing.T) {
+	if _, err := readFreshclamDat("testdata/1-socket.txt"); err == nil {
+		t.Error("readFreshclamDat() = _, nil; want error")
+	}
+}
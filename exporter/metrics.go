@@ -1,10 +1,18 @@
 package exporter
 
+import (
+	"time"
+
+	"github.com/sergeymakinen/clamav_exporter/v2/clamd"
+)
+
 type metrics struct {
-	Version *string
-	DB      *db
-	Pools   []pool
-	Memory  memory
+	Version   *string
+	DB        *db
+	Pools     []clamd.Pool
+	Memory    clamd.Memory
+	ScanProbe *scanProbe
+	Scrape    *scrapeStats
 }
 
 type db struct {
@@ -12,33 +20,17 @@ type db struct {
 	Time    string
 }
 
-type pool struct {
-	State   string
-	Primary bool
-	Threads threads
-	Queue   queue
-}
-
-type queue struct {
-	Length  int64
-	MinWait float64
-	MaxWait float64
-	AvgWait float64
+// time returns the database build time.
+func (d *db) time() (time.Time, error) {
+	return time.ParseInLocation(clamd.TimeLayout, d.Time, tz)
 }
 
-type threads struct {
-	Live        *int64
-	Idle        *int64
-	Max         *int64
-	IdleTimeout *int64
+type scrapeStats struct {
+	Duration     float64
+	ResponseSize int
 }
 
-type memory struct {
-	Heap       *uint64
-	Mmap       *uint64
-	Used       *uint64
-	Free       *uint64
-	Releasable *uint64
-	PoolsUsed  *uint64
-	PoolsTotal *uint64
+type scanProbe struct {
+	Signature string
+	Duration  float64
 }
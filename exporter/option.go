@@ -0,0 +1,133 @@
+package exporter
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+)
+
+// Names of the metric groups that can be enabled with WithCollectors.
+const (
+	CollectorVersion   = "version"
+	CollectorPools     = "pools"
+	CollectorMemory    = "memory"
+	CollectorScan      = "scan"
+	CollectorPublished = "published"
+)
+
+// Collectors lists all the metric groups.
+var Collectors = []string{
+	CollectorVersion,
+	CollectorPools,
+	CollectorMemory,
+	CollectorScan,
+	CollectorPublished,
+}
+
+// DefaultCollectors lists the metric groups enabled by default.
+var DefaultCollectors = []string{
+	CollectorVersion,
+	CollectorPools,
+	CollectorMemory,
+}
+
+// Default delays between retries of a scrape.
+const (
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultMaxRetryBackoff = 5 * time.Second
+)
+
+// Option configures an Exporter.
+type Option func(e *Exporter) error
+
+// WithTLSConfig makes the exporter connect to ClamAV daemon TCP sockets using TLS.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(e *Exporter) error {
+		e.tlsConfig = cfg
+		return nil
+	}
+}
+
+// WithCollectors enables only the given metric groups.
+func WithCollectors(names ...string) Option {
+	return func(e *Exporter) error {
+		collectors := make(map[string]bool, len(names))
+		for _, name := range names {
+			if !slices.Contains(Collectors, name) {
+				return fmt.Errorf("unknown collector %q", name)
+			}
+			collectors[name] = true
+		}
+		e.collectors = collectors
+		return nil
+	}
+}
+
+// WithMaxResultAge makes the exporter reuse the statistics of a previous scrape
+// for up to d instead of scraping ClamAV again.
+func WithMaxResultAge(d time.Duration) Option {
+	return func(e *Exporter) error {
+		if d < 0 {
+			return fmt.Errorf("invalid max result age %s", d)
+		}
+		e.maxResultAge = d
+		return nil
+	}
+}
+
+// WithPersistentSession makes the exporter keep the IDSESSION session
+// open between scrapes instead of connecting to ClamAV on every scrape.
+// The session is reopened if it fails. Close closes it.
+func WithPersistentSession() Option {
+	return func(e *Exporter) error {
+		e.persistentSession = true
+		return nil
+	}
+}
+
+// WithRetryBackoff sets the delays between retries of a scrape. The delay
+// doubles after every failed attempt from min up to max with a random jitter.
+// Zero min makes the exporter retry immediately.
+func WithRetryBackoff(min, max time.Duration) Option {
+	return func(e *Exporter) error {
+		if min < 0 || max < min {
+			return fmt.Errorf("invalid retry backoff %s-%s", min, max)
+		}
+		e.retryBackoff = min
+		e.maxRetryBackoff = max
+		return nil
+	}
+}
+
+// WithDBMaxAge makes the exporter report the ClamAV Virus Database as stale
+// if it's older than d.
+func WithDBMaxAge(d time.Duration) Option {
+	return func(e *Exporter) error {
+		if d < 0 {
+			return fmt.Errorf("invalid database max age %s", d)
+		}
+		e.dbMaxAge = d
+		return nil
+	}
+}
+
+// WithPublishedRecord sets the DNS TXT record to get the published versions from
+// and the DNS resolver address to resolve it with. An empty record means
+// DefaultPublishedRecord and an empty resolver means the system resolver.
+func WithPublishedRecord(record, resolver string) Option {
+	return func(e *Exporter) error {
+		if record == "" {
+			record = DefaultPublishedRecord
+		}
+		if resolver != "" {
+			if _, _, err := net.SplitHostPort(resolver); err != nil {
+				return fmt.Errorf("invalid resolver address %q: %w", resolver, err)
+			}
+		}
+		e.publishedRecord = record
+		e.resolver = resolver
+		return nil
+	}
+}
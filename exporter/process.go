@@ -0,0 +1,148 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+// ProcessCollector collects the ClamAV daemon process stats from /proc
+// and exports them using the prometheus metrics package.
+type ProcessCollector struct {
+	pidFile  string
+	name     string
+	procPath string
+	logger   *slog.Logger
+
+	residentMemory *prometheus.Desc
+	virtualMemory  *prometheus.Desc
+	cpuTime        *prometheus.Desc
+	openFDs        *prometheus.Desc
+	threads        *prometheus.Desc
+	startTime      *prometheus.Desc
+}
+
+// Describe describes all the metrics exported by the collector. It
+// implements prometheus.Collector.
+func (c *ProcessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.residentMemory
+	ch <- c.virtualMemory
+	ch <- c.cpuTime
+	ch <- c.openFDs
+	ch <- c.threads
+	ch <- c.startTime
+}
+
+// Collect finds the ClamAV daemon process, reads its stats, and
+// delivers them as Prometheus metrics. It implements prometheus.Collector.
+func (c *ProcessCollector) Collect(ch chan<- prometheus.Metric) {
+	fs, err := procfs.NewFS(c.procPath)
+	if err != nil {
+		c.logger.Error("Failed to open procfs", "path", c.procPath, "err", err)
+		return
+	}
+	p, err := c.find(fs)
+	if err != nil {
+		c.logger.Error("Failed to find clamd process", "err", err)
+		return
+	}
+	stat, err := p.Stat()
+	if err != nil {
+		c.logger.Error("Failed to read clamd process stats", "pid", p.PID, "err", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.residentMemory, prometheus.GaugeValue, float64(stat.ResidentMemory()))
+	ch <- prometheus.MustNewConstMetric(c.virtualMemory, prometheus.GaugeValue, float64(stat.VirtualMemory()))
+	ch <- prometheus.MustNewConstMetric(c.cpuTime, prometheus.CounterValue, stat.CPUTime())
+	ch <- prometheus.MustNewConstMetric(c.threads, prometheus.GaugeValue, float64(stat.NumThreads))
+	if startTime, err := stat.StartTime(); err == nil {
+		ch <- prometheus.MustNewConstMetric(c.startTime, prometheus.GaugeValue, startTime)
+	} else {
+		c.logger.Error("Failed to read clamd process start time", "pid", p.PID, "err", err)
+	}
+	if fds, err := p.FileDescriptorsLen(); err == nil {
+		ch <- prometheus.MustNewConstMetric(c.openFDs, prometheus.GaugeValue, float64(fds))
+	} else {
+		c.logger.Error("Failed to read clamd process file descriptors", "pid", p.PID, "err", err)
+	}
+}
+
+// find returns the process with the PID from the pid file if it's set,
+// otherwise the first process with the name.
+func (c *ProcessCollector) find(fs procfs.FS) (procfs.Proc, error) {
+	if c.pidFile != "" {
+		b, err := os.ReadFile(c.pidFile)
+		if err != nil {
+			return procfs.Proc{}, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			return procfs.Proc{}, fmt.Errorf("invalid pid file %s: %w", c.pidFile, err)
+		}
+		return fs.Proc(pid)
+	}
+	procs, err := fs.AllProcs()
+	if err != nil {
+		return procfs.Proc{}, err
+	}
+	for _, p := range procs {
+		if comm, err := p.Comm(); err == nil && comm == c.name {
+			return p, nil
+		}
+	}
+	return procfs.Proc{}, errors.New("no process named " + c.name)
+}
+
+// NewProcessCollector returns an initialized process collector. The process
+// is found by the PID in pidFile or, if it's empty, by its name.
+func NewProcessCollector(pidFile, name string, logger *slog.Logger) *ProcessCollector {
+	return &ProcessCollector{
+		pidFile:  pidFile,
+		name:     name,
+		procPath: procfs.DefaultMountPoint,
+		logger:   logger,
+
+		residentMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "resident_memory_bytes"),
+			"Resident memory size of the ClamAV daemon in bytes.",
+			nil,
+			nil,
+		),
+		virtualMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "virtual_memory_bytes"),
+			"Virtual memory size of the ClamAV daemon in bytes.",
+			nil,
+			nil,
+		),
+		cpuTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "cpu_seconds_total"),
+			"Total user and system CPU time spent by the ClamAV daemon in seconds.",
+			nil,
+			nil,
+		),
+		openFDs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "open_fds"),
+			"Number of open file descriptors of the ClamAV daemon.",
+			nil,
+			nil,
+		),
+		threads: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "threads"),
+			"Number of threads of the ClamAV daemon.",
+			nil,
+			nil,
+		),
+		startTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "start_time_seconds"),
+			"Start time of the ClamAV daemon since unix epoch in seconds.",
+			nil,
+			nil,
+		),
+	}
+}
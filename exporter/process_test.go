@@ -0,0 +1,87 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestProcessCollector_Collect(t *testing.T) {
+	dir := t.TempDir()
+	procPath := filepath.Join(dir, "proc")
+	writeFile(t, filepath.Join(procPath, "stat"), "btime 1733700000\n")
+	writeFile(t, filepath.Join(procPath, "1", "comm"), "init\n")
+	writeFile(t, filepath.Join(procPath, "1", "stat"), "1 (init) S 0 1 1 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 1 1024 10 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n")
+	writeFile(t, filepath.Join(procPath, "42", "comm"), "clamd\n")
+	// utime 500 and stime 250 ticks, 12 threads, start time 1000 ticks, 1 GiB virtual memory and 1000 pages resident.
+	writeFile(t, filepath.Join(procPath, "42", "stat"), "42 (clamd) S 1 42 42 0 -1 4194560 0 0 0 0 500 250 0 0 20 0 12 0 1000 1073741824 1000 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n")
+	if err := os.MkdirAll(filepath.Join(procPath, "42", "fd"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := os.Symlink("/dev/null", filepath.Join(procPath, "42", "fd", fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pidFile := filepath.Join(dir, "clamd.pid")
+	writeFile(t, pidFile, "42\n")
+	want := fmt.Sprintf(`# HELP clamav_process_cpu_seconds_total Total user and system CPU time spent by the ClamAV daemon in seconds.
+# TYPE clamav_process_cpu_seconds_total counter
+clamav_process_cpu_seconds_total 7.5
+# HELP clamav_process_open_fds Number of open file descriptors of the ClamAV daemon.
+# TYPE clamav_process_open_fds gauge
+clamav_process_open_fds 3
+# HELP clamav_process_resident_memory_bytes Resident memory size of the ClamAV daemon in bytes.
+# TYPE clamav_process_resident_memory_bytes gauge
+clamav_process_resident_memory_bytes %d
+# HELP clamav_process_start_time_seconds Start time of the ClamAV daemon since unix epoch in seconds.
+# TYPE clamav_process_start_time_seconds gauge
+clamav_process_start_time_seconds 1.73370001e+09
+# HELP clamav_process_threads Number of threads of the ClamAV daemon.
+# TYPE clamav_process_threads gauge
+clamav_process_threads 12
+# HELP clamav_process_virtual_memory_bytes Virtual memory size of the ClamAV daemon in bytes.
+# TYPE clamav_process_virtual_memory_bytes gauge
+clamav_process_virtual_memory_bytes 1.073741824e+09
+`, 1000*os.Getpagesize())
+	tests := []struct {
+		name    string
+		pidFile string
+		process string
+	}{
+		{name: "pid file", pidFile: pidFile},
+		{name: "process name", process: "clamd"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewProcessCollector(test.pidFile, test.process, promslog.NewNopLogger())
+			c.procPath = procPath
+			if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+				t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+			}
+		})
+	}
+}
+
+func TestProcessCollector_Collect_NotFound(t *testing.T) {
+	procPath := t.TempDir()
+	c := NewProcessCollector("", "clamd", promslog.NewNopLogger())
+	c.procPath = procPath
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("testutil.CollectAndCount() = %d; want 0", n)
+	}
+}
+
+func writeFile(t *testing.T, name, data string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPublishedRecord is the DNS TXT record freshclam gets the published versions from.
+const DefaultPublishedRecord = "current.cvd.clamav.net"
+
+const (
+	// publishedTTL is how long the published versions are cached for.
+	// They're published a few times a day at most.
+	publishedTTL = 30 * time.Minute
+	// publishedRetryDelay is how long a failed lookup isn't retried for,
+	// so an unreachable resolver doesn't delay every scrape.
+	publishedRetryDelay = time.Minute
+)
+
+// published are the versions published in the DNS TXT record.
+type published struct {
+	Engine   string
+	Main     uint32
+	Daily    uint32
+	Bytecode uint32
+	Time     time.Time
+}
+
+// parsePublished parses the DNS TXT record, e.g.:
+//
+//	1.4.1:62:27482:1733736720:1:90:49192:336
+//
+// The fields are the engine version, the main and daily database versions,
+// the daily database publish time, unused fields and the bytecode database version.
+func parsePublished(txt string) (*published, error) {
+	fields := strings.Split(txt, ":")
+	if len(fields) < 8 {
+		return nil, fmt.Errorf("invalid record %q", txt)
+	}
+	p := &published{Engine: fields[0]}
+	for _, v := range []struct {
+		field int
+		n     *uint32
+	}{
+		{field: 1, n: &p.Main},
+		{field: 2, n: &p.Daily},
+		{field: 7, n: &p.Bytecode},
+	} {
+		n, err := strconv.ParseUint(fields[v.field], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q in record %q", fields[v.field], txt)
+		}
+		*v.n = uint32(n)
+	}
+	t, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q in record %q", fields[3], txt)
+	}
+	p.Time = time.Unix(t, 0)
+	return p, nil
+}
+
+// lookupPublished resolves the DNS TXT record with the published versions.
+// An empty resolver address means the system resolver.
+func lookupPublished(ctx context.Context, resolver, record string) (*published, error) {
+	r := net.DefaultResolver
+	if resolver != "" {
+		r = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, resolver)
+			},
+		}
+	}
+	txts, err := r.LookupTXT(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	if len(txts) == 0 {
+		return nil, fmt.Errorf("no TXT records for %s", record)
+	}
+	return parsePublished(txts[0])
+}
+
+// publishedCache is the last lookup of the published versions.
+type publishedCache struct {
+	record   string
+	resolver string
+	p        *published
+	expires  time.Time
+}
+
+// publishedVersions returns the published versions, resolving the DNS TXT record
+// once the cached ones expire or the record or resolver change. The lookup
+// times out after the exporter timeout and doesn't hold the ClamAV session.
+// It returns nil if the lookup failed.
+func (e *Exporter) publishedVersions(ctx context.Context) *published {
+	e.mu.Lock()
+	var (
+		timeout  = e.timeout
+		record   = e.publishedRecord
+		resolver = e.resolver
+	)
+	e.mu.Unlock()
+	e.publishedMu.Lock()
+	defer e.publishedMu.Unlock()
+	c := &e.published
+	if c.record == record && c.resolver == resolver && time.Now().Before(c.expires) {
+		return c.p
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	p, err := lookupPublished(lookupCtx, resolver, record)
+	if err != nil {
+		if ctx.Err() != nil {
+			// The scrape is canceled, so the lookup is retried on the next one.
+			e.logger.Debug("Lookup of published versions canceled", "record", record, "err", err)
+			return nil
+		}
+		e.logger.Error("Failed to look up published versions", "record", record, "err", err)
+		e.publishedLookupErrors.Inc()
+		*c = publishedCache{record: record, resolver: resolver, expires: time.Now().Add(publishedRetryDelay)}
+		return nil
+	}
+	*c = publishedCache{record: record, resolver: resolver, p: p, expires: time.Now().Add(publishedTTL)}
+	return p
+}
@@ -0,0 +1,194 @@
+package exporter
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestParsePublished(t *testing.T) {
+	tests := []struct {
+		txt  string
+		want *published
+	}{
+		{
+			txt: "1.4.1:62:27482:1733736720:1:90:49192:336",
+			want: &published{
+				Engine:   "1.4.1",
+				Main:     62,
+				Daily:    27482,
+				Bytecode: 336,
+				Time:     time.Unix(1733736720, 0),
+			},
+		},
+		{txt: "1.4.1:62:27482"},
+		{txt: "1.4.1:62:foo:1733736720:1:90:49192:336"},
+		{txt: "1.4.1:62:27482:foo:1:90:49192:336"},
+	}
+	for _, test := range tests {
+		t.Run(test.txt, func(t *testing.T) {
+			p, err := parsePublished(test.txt)
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("parsePublished() = %+v, nil; want error", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePublished() = _, %v; want nil", err)
+			}
+			if !reflect.DeepEqual(p, test.want) {
+				t.Errorf("parsePublished() = %+v, nil; want %+v", p, test.want)
+			}
+		})
+	}
+}
+
+func TestExporter_Published(t *testing.T) {
+	resolver := serveDNS(t, map[string]string{
+		"current.cvd.example.com.": "1.4.1:62:27490:1733736720:1:90:49192:336",
+	})
+	address := serveClamd(t, map[string]string{
+		"PING":    "PONG",
+		"VERSION": "ClamAV 1.4.1/27482/Mon Dec  9 09:37:53 2024",
+		"STATS":   "POOLS: 1\n\nSTATE: VALID PRIMARY\nEND",
+	})
+	exporter, err := New(address, time.Second, 0, promslog.NewNopLogger(),
+		WithCollectors(CollectorVersion, CollectorPublished),
+		WithPublishedRecord("current.cvd.example.com", resolver),
+	)
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	want := `# HELP clamav_db_versions_behind Number of published daily database versions the installed one is behind.
+# TYPE clamav_db_versions_behind gauge
+clamav_db_versions_behind 8
+# HELP clamav_published_db_timestamp_seconds Unix timestamp of the latest published ClamAV daily database.
+# TYPE clamav_published_db_timestamp_seconds gauge
+clamav_published_db_timestamp_seconds 1.73373672e+09
+# HELP clamav_published_lookup_errors_total Number of failed lookups of the published ClamAV versions.
+# TYPE clamav_published_lookup_errors_total counter
+clamav_published_lookup_errors_total 0
+# HELP clamav_published_db_version The latest published ClamAV Virus Database version.
+# TYPE clamav_published_db_version gauge
+clamav_published_db_version{database="bytecode"} 336
+clamav_published_db_version{database="daily"} 27490
+clamav_published_db_version{database="main"} 62
+# HELP clamav_published_version The latest published version of ClamAV.
+# TYPE clamav_published_version gauge
+clamav_published_version{version="1.4.1"} 1
+`
+	metricNames := []string{
+		"clamav_db_versions_behind",
+		"clamav_published_db_timestamp_seconds",
+		"clamav_published_db_version",
+		"clamav_published_lookup_errors_total",
+		"clamav_published_version",
+	}
+	if err = testutil.CollectAndCompare(exporter, strings.NewReader(want), metricNames...); err != nil {
+		t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+	}
+}
+
+func TestExporter_Published_LookupError(t *testing.T) {
+	// The resolver never replies, so the lookup times out.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	address := serveClamd(t, map[string]string{
+		"PING":    "PONG",
+		"VERSION": "ClamAV 1.4.1/27482/Mon Dec  9 09:37:53 2024",
+		"STATS":   "POOLS: 1\n\nSTATE: VALID PRIMARY\nEND",
+	})
+	exporter, err := New(address, 200*time.Millisecond, 0, promslog.NewNopLogger(),
+		WithCollectors(CollectorVersion, CollectorPublished),
+		WithPublishedRecord("current.cvd.example.com", conn.LocalAddr().String()),
+	)
+	if err != nil {
+		t.Fatalf("New() = _, %v; want nil", err)
+	}
+	want := `# HELP clamav_published_lookup_errors_total Number of failed lookups of the published ClamAV versions.
+# TYPE clamav_published_lookup_errors_total counter
+clamav_published_lookup_errors_total 1
+# HELP clamav_up Was the last scrape successful.
+# TYPE clamav_up gauge
+clamav_up 1
+`
+	metricNames := []string{
+		"clamav_db_versions_behind",
+		"clamav_published_lookup_errors_total",
+		"clamav_published_version",
+		"clamav_up",
+	}
+	for i := range 2 {
+		// The failed lookup isn't retried on the second scrape.
+		start := time.Now()
+		if err = testutil.CollectAndCompare(exporter, strings.NewReader(want), metricNames...); err != nil {
+			t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+		}
+		if d := time.Since(start); i > 0 && d >= 200*time.Millisecond {
+			t.Errorf("Collect() took %v; want the failed lookup cached", d)
+		}
+	}
+	if r := exporter.lastResult(); r == nil || r.m.Scrape == nil || r.m.Scrape.Duration >= 0.2 {
+		t.Errorf("lastResult() = %+v; want the lookup not to count to the scrape duration", r)
+	}
+}
+
+func TestLookupPublished_NotFound(t *testing.T) {
+	resolver := serveDNS(t, nil)
+	if p, err := lookupPublished(context.Background(), resolver, "current.cvd.example.com"); err == nil {
+		t.Errorf("lookupPublished() = %+v, nil; want error", p)
+	}
+}
+
+// serveDNS starts a fake DNS server replying to TXT queries with the given records by name.
+func serveDNS(t *testing.T, records map[string]string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			var msg dnsmessage.Message
+			if err = msg.Unpack(b[:n]); err != nil || len(msg.Questions) != 1 {
+				continue
+			}
+			q := msg.Questions[0]
+			msg.Header.Response = true
+			msg.Header.Authoritative = true
+			txt, ok := records[q.Name.String()]
+			if ok && q.Type == dnsmessage.TypeTXT {
+				msg.Answers = []dnsmessage.Resource{
+					{
+						Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60},
+						Body:   &dnsmessage.TXTResource{TXT: []string{txt}},
+					},
+				}
+			} else if !ok {
+				msg.Header.RCode = dnsmessage.RCodeNameError
+			}
+			out, err := msg.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(out, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
@@ -0,0 +1,187 @@
+package exporter
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var reYaraRule = regexp.MustCompile(`^\s*(?:(?:private|global)\s+)*rule\s+\w`)
+
+// Kinds of signature files.
+const (
+	signatureBinary = iota
+	signatureLines
+	signatureYara
+)
+
+// signatureFormats maps the extensions of the signature files
+// loaded by the ClamAV daemon to their kinds.
+var signatureFormats = map[string]int{
+	"cat":  signatureLines,
+	"cbc":  signatureBinary,
+	"cdb":  signatureLines,
+	"cfg":  signatureLines,
+	"crb":  signatureLines,
+	"fp":   signatureLines,
+	"ftm":  signatureLines,
+	"gdb":  signatureLines,
+	"hdb":  signatureLines,
+	"hdu":  signatureLines,
+	"hsb":  signatureLines,
+	"hsu":  signatureLines,
+	"idb":  signatureLines,
+	"ign":  signatureLines,
+	"ign2": signatureLines,
+	"imp":  signatureLines,
+	"ldb":  signatureLines,
+	"ldu":  signatureLines,
+	"mdb":  signatureLines,
+	"mdu":  signatureLines,
+	"msb":  signatureLines,
+	"msu":  signatureLines,
+	"ndb":  signatureLines,
+	"ndu":  signatureLines,
+	"pdb":  signatureLines,
+	"pwdb": signatureLines,
+	"sfp":  signatureLines,
+	"wdb":  signatureLines,
+	"yar":  signatureYara,
+	"yara": signatureYara,
+}
+
+// SignatureCollector collects stats of the signature files other than the
+// official ClamAV Virus Databases (e.g. third-party feeds) from a directory
+// and exports them using the prometheus metrics package.
+type SignatureCollector struct {
+	dir    string
+	logger *slog.Logger
+	mu     sync.Mutex
+	counts map[string]signatureCount
+
+	modTime    *prometheus.Desc
+	size       *prometheus.Desc
+	signatures *prometheus.Desc
+}
+
+type signatureCount struct {
+	ModTime time.Time
+	Size    int64
+	Count   int
+}
+
+// Describe describes all the metrics exported by the collector. It
+// implements prometheus.Collector.
+func (c *SignatureCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.modTime
+	ch <- c.size
+	ch <- c.signatures
+}
+
+// Collect lists the signature files, and
+// delivers their stats as Prometheus metrics. It implements prometheus.Collector.
+func (c *SignatureCollector) Collect(ch chan<- prometheus.Metric) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		c.logger.Error("Failed to read database directory", "dir", c.dir, "err", err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]signatureCount)
+	for _, entry := range entries {
+		format := strings.TrimPrefix(filepath.Ext(entry.Name()), ".")
+		kind, ok := signatureFormats[format]
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			c.logger.Error("Failed to stat signature file", "file", entry.Name(), "err", err)
+			continue
+		}
+		labelValues := []string{entry.Name(), format}
+		ch <- prometheus.MustNewConstMetric(c.modTime, prometheus.GaugeValue, float64(fi.ModTime().Unix()), labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(fi.Size()), labelValues...)
+		if kind == signatureBinary {
+			continue
+		}
+		// Counting signatures of big files on every scrape is expensive, so cache the count until the file changes.
+		count, ok := c.counts[entry.Name()]
+		if !ok || !count.ModTime.Equal(fi.ModTime()) || count.Size != fi.Size() {
+			n, err := countSignatures(filepath.Join(c.dir, entry.Name()), kind)
+			if err != nil {
+				c.logger.Error("Failed to count signatures", "file", entry.Name(), "err", err)
+				continue
+			}
+			count = signatureCount{
+				ModTime: fi.ModTime(),
+				Size:    fi.Size(),
+				Count:   n,
+			}
+		}
+		counts[entry.Name()] = count
+		ch <- prometheus.MustNewConstMetric(c.signatures, prometheus.GaugeValue, float64(count.Count), labelValues...)
+	}
+	c.counts = counts
+}
+
+// countSignatures counts the non-empty non-comment lines of a line-based
+// signature file or the rules of a YARA file.
+func countSignatures(name string, kind int) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var n int
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if kind == signatureLines {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				n++
+			}
+		} else if reYaraRule.MatchString(line) {
+			n++
+		}
+	}
+	return n, s.Err()
+}
+
+// NewSignatureCollector returns an initialized signature collector
+// for the database directory.
+func NewSignatureCollector(dir string, logger *slog.Logger) *SignatureCollector {
+	labels := []string{"file", "format"}
+	return &SignatureCollector{
+		dir:    dir,
+		logger: logger,
+
+		modTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "signature_file", "modified_timestamp_seconds"),
+			"Unix timestamp of the signature file modification time.",
+			labels,
+			nil,
+		),
+		size: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "signature_file", "size_bytes"),
+			"Size of the signature file in bytes.",
+			labels,
+			nil,
+		),
+		signatures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "signature_file", "signatures"),
+			"Number of signatures in the signature file.",
+			labels,
+			nil,
+		),
+	}
+}
@@ -0,0 +1,56 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestSignatureCollector_Collect(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"junk.ndb":      "# Sanesecurity junk\nSanesecurity.Junk.1:0:*:6a756e6b\n\nSanesecurity.Junk.2:0:*:4a554e4b\n",
+		"custom.hdb":    "44d88612fea8a8f36de82e1278abb02f:68:Eicar-Test-Signature\n",
+		"rules.yara":    "rule foo\n{\n  condition: true\n}\nprivate rule bar { condition: false }\n",
+		"daily.cld":     "ClamAV-VDB:",
+		"freshclam.dat": "",
+	}
+	modTime := time.Unix(1733737073, 0)
+	for name, data := range files {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collector := NewSignatureCollector(dir, promslog.NewNopLogger())
+	want := `# HELP clamav_signature_file_modified_timestamp_seconds Unix timestamp of the signature file modification time.
+# TYPE clamav_signature_file_modified_timestamp_seconds gauge
+clamav_signature_file_modified_timestamp_seconds{file="custom.hdb",format="hdb"} 1.733737073e+09
+clamav_signature_file_modified_timestamp_seconds{file="junk.ndb",format="ndb"} 1.733737073e+09
+clamav_signature_file_modified_timestamp_seconds{file="rules.yara",format="yara"} 1.733737073e+09
+# HELP clamav_signature_file_signatures Number of signatures in the signature file.
+# TYPE clamav_signature_file_signatures gauge
+clamav_signature_file_signatures{file="custom.hdb",format="hdb"} 1
+clamav_signature_file_signatures{file="junk.ndb",format="ndb"} 2
+clamav_signature_file_signatures{file="rules.yara",format="yara"} 2
+# HELP clamav_signature_file_size_bytes Size of the signature file in bytes.
+# TYPE clamav_signature_file_size_bytes gauge
+clamav_signature_file_size_bytes{file="custom.hdb",format="hdb"} 57
+clamav_signature_file_size_bytes{file="junk.ndb",format="ndb"} 87
+clamav_signature_file_size_bytes{file="rules.yara",format="yara"} 69
+`
+	// Collect twice to check cached counts.
+	for i := 0; i < 2; i++ {
+		if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+			t.Errorf("testutil.CollectAndCompare() = %v; want nil", err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxLineSize is the maximum size of a line kept in memory.
+// Longer lines are truncated.
+const maxLineSize = 64 * 1024
+
+// tailer incrementally reads lines appended to a file,
+// following the file when it's rotated or truncated.
+// The file is read in chunks, so large files aren't loaded into memory.
+type tailer struct {
+	name   string
+	file   *os.File
+	reader *bufio.Reader
+	// offset is the number of bytes read from the file.
+	offset int64
+	// partial is the incomplete last line read, completed by the next call.
+	partial []byte
+}
+
+// lines calls fn for the complete lines appended to the file since the last call.
+// The first call reads all the lines of the file.
+func (t *tailer) lines(fn func(line string)) error {
+	fi, err := os.Stat(t.name)
+	if err != nil {
+		return err
+	}
+	if t.file != nil {
+		cur, err := t.file.Stat()
+		if err != nil || !os.SameFile(fi, cur) {
+			// The file was rotated, so read what was appended to the old one before switching.
+			if err == nil {
+				t.read(fn)
+			}
+			t.file.Close()
+			t.file = nil
+		} else if fi.Size() < t.offset {
+			if _, err = t.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			t.reset()
+		}
+	}
+	if t.file == nil {
+		if t.file, err = os.Open(t.name); err != nil {
+			return err
+		}
+		t.reset()
+	}
+	return t.read(fn)
+}
+
+func (t *tailer) reset() {
+	t.reader = bufio.NewReader(t.file)
+	t.offset = 0
+	t.partial = t.partial[:0]
+}
+
+func (t *tailer) read(fn func(line string)) error {
+	for {
+		b, err := t.reader.ReadSlice('\n')
+		t.offset += int64(len(b))
+		if n := maxLineSize - len(t.partial); n > 0 {
+			t.partial = append(t.partial, b[:min(n, len(b))]...)
+		}
+		switch err {
+		case nil:
+			fn(strings.TrimSuffix(string(t.partial), "\n"))
+			t.partial = t.partial[:0]
+		case bufio.ErrBufferFull:
+		case io.EOF:
+			// Leave an incomplete last line for the next call.
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// parseLogLine splits a ClamAV log line into the time and the message.
+// If the line has no time (LogTime is disabled), the current time is returned.
+func parseLogLine(line string) (time.Time, string) {
+	line = strings.TrimRight(line, "\r")
+	if s, msg, ok := strings.Cut(line, " -> "); ok {
+		if t, err := time.ParseInLocation("Mon Jan _2 15:04:05 2006", s, tz); err == nil {
+			return t, msg
+		}
+	}
+	return time.Now(), line
+}
@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTailer_lines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "clamd.log")
+	appendFile := func(s string) {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err = f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tailer := &tailer{name: name}
+	tests := []struct {
+		name   string
+		before func()
+		want   []string
+	}{
+		{
+			name:   "initial",
+			before: func() { appendFile("a\nb\nc") },
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "append",
+			before: func() { appendFile("\nd\n") },
+			want:   []string{"c", "d"},
+		},
+		{
+			name:   "no changes",
+			before: func() {},
+			want:   nil,
+		},
+		{
+			name: "rotate",
+			before: func() {
+				appendFile("e\n")
+				if err := os.Rename(name, name+".1"); err != nil {
+					t.Fatal(err)
+				}
+				appendFile("f\nff\n")
+			},
+			want: []string{"e", "f", "ff"},
+		},
+		{
+			name: "truncate",
+			before: func() {
+				if err := os.WriteFile(name, []byte("g\n"), 0666); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"g"},
+		},
+		{
+			name:   "long line",
+			before: func() { appendFile(strings.Repeat("h", maxLineSize) + "hh\ni\n") },
+			want:   []string{strings.Repeat("h", maxLineSize), "i"},
+		},
+	}
+	for _, test := range tests {
+		test.before()
+		var lines []string
+		err := tailer.lines(func(line string) { lines = append(lines, line) })
+		if err != nil {
+			t.Fatalf("%s: lines() = _, %v; want nil", test.name, err)
+		}
+		if !slices.Equal(lines, test.want) {
+			t.Errorf("%s: lines() = %q; want %q", test.name, lines, test.want)
+		}
+	}
+}